@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/admin"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/internal/thumbnail"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const usage = `usage: spooky-server [command]
+
+without a command the server is started.
+
+commands:
+  users set-role [-reason text] <user-id | platform:platform-user-id> <player|mod|agent|admin>
+  blobs migrate
+      moves level content, replays and thumbnails still stored in the database into the blob store
+`
+
+var errUsage = errors.New(strings.TrimSpace(usage))
+
+func runCommand(db *gorm.DB, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	switch args[0] + " " + args[1] {
+	case "users set-role":
+		return usersSetRole(db, args[2:])
+	case "blobs migrate":
+		return blobsMigrate(db)
+	default:
+		return errUsage
+	}
+}
+
+// findUser resolves a user by id or by platform:platformUserId
+func findUser(db *gorm.DB, ref string) (*model.User, error) {
+	var user model.User
+
+	tx := db.Limit(1)
+
+	if platformType, platformUserID, found := strings.Cut(ref, ":"); found {
+		tx = tx.Where("id = (?)", db.
+			Model(&model.UserIdentity{}).
+			Select("user_id").
+			Where(&model.UserIdentity{PlatformType: platformType, PlatformUserID: platformUserID}))
+	} else {
+		userID, err := uuid.Parse(ref)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tx = tx.Where("id = ?", userID)
+	}
+
+	tx = tx.Find(&user)
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return nil, admin.ErrUserNotFound
+	}
+
+	return &user, nil
+}
+
+func usersSetRole(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("users set-role", flag.ContinueOnError)
+	reason := flags.String("reason", "", "reason recorded in the audit trail")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		return errUsage
+	}
+
+	user, err := findUser(db, flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	previousRole := user.Role
+
+	if _, err := admin.SetUserRole(db, user.ID, flags.Arg(1), nil, model.AuditSourceCLI, *reason); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s): %s -> %s\n", user.ID, user.PlatformName, previousRole, flags.Arg(1))
+
+	return nil
+}
+
+func blobsMigrate(db *gorm.DB) error {
+	report := func(line string) {
+		fmt.Println(line)
+	}
+
+	if err := levelcontent.Migrate(db, report); err != nil {
+		return err
+	}
+
+	return thumbnail.MigrateBlobs(db, report)
+}
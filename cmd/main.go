@@ -5,8 +5,16 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/Lyretto/spooky-bodies-golang/internal/account"
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
 	"github.com/Lyretto/spooky-bodies-golang/internal/config"
 	"github.com/Lyretto/spooky-bodies-golang/internal/controller"
+	"github.com/Lyretto/spooky-bodies-golang/internal/job"
+	"github.com/Lyretto/spooky-bodies-golang/internal/leaderboard"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelquery"
+	"github.com/Lyretto/spooky-bodies-golang/internal/review"
+	"github.com/Lyretto/spooky-bodies-golang/internal/revision"
+	"github.com/Lyretto/spooky-bodies-golang/internal/thumbnail"
 	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,13 +22,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func main() {
-	fmt.Println("hello from spooky bodies server!")
-
-	config.Init()
-
-	os.Setenv("TOKEN_HOUR_LIFESPAN", strconv.Itoa(config.C.TokenLifeSpan))
-
+func openDatabase() *gorm.DB {
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Europe/Berlin",
 		config.C.Database.Host,
@@ -43,12 +45,100 @@ func main() {
 		&model.Validation{},
 		&model.Report{},
 		&model.UserToken{},
+		&model.JobRun{},
+		&model.AuditEntry{},
+		&model.APIKey{},
+		&model.Ban{},
+		&model.UserIdentity{},
+		&model.DeletionRequest{},
+		&model.LevelRevision{},
+		&model.Thumbnail{},
+		&model.Play{},
+		&model.Score{},
 	); err != nil {
 		panic(err)
 	}
 
+	if err := account.MigrateIdentities(db); err != nil {
+		panic(err)
+	}
+
+	if err := account.MigratePlatformIndex(db); err != nil {
+		panic(err)
+	}
+
+	if err := levelquery.MigrateIndexes(db); err != nil {
+		panic(err)
+	}
+
+	if err := revision.MigrateRevisions(db); err != nil {
+		panic(err)
+	}
+
+	if err := review.MigrateStates(db); err != nil {
+		panic(err)
+	}
+
+	if err := review.MigrateShareCodes(db); err != nil {
+		panic(err)
+	}
+
+	if err := thumbnail.MigrateIndex(db); err != nil {
+		panic(err)
+	}
+
+	if err := thumbnail.MigrateInline(db); err != nil {
+		panic(err)
+	}
+
+	if err := leaderboard.MigrateScoreIndex(db); err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+func main() {
+	config.Init()
+
+	if err := blob.Init(); err != nil {
+		panic(err)
+	}
+
+	// subcommands work directly against the database without starting the server
+	if len(os.Args) > 1 {
+		if err := runCommand(openDatabase(), os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	fmt.Println("hello from spooky bodies server!")
+
+	os.Setenv("TOKEN_HOUR_LIFESPAN", strconv.Itoa(config.C.TokenLifeSpan))
+
+	db := openDatabase()
+
+	scheduler := job.NewScheduler(db)
+
+	job.UseCleanup(scheduler)
+	job.UseAccount(scheduler)
+	job.UseVoting(scheduler)
+	job.UseRanking(scheduler)
+	job.UsePlay(scheduler)
+	job.UseBlob(scheduler)
+
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	router := gin.New()
 
+	if err := controller.UseTrustedProxies(router); err != nil {
+		panic(err)
+	}
+
 	corsConfig := cors.DefaultConfig()
 
 	corsConfig.AllowAllOrigins = true
@@ -57,8 +147,22 @@ func main() {
 
 	router.Use(cors.New(corsConfig))
 
-	controller.UseAuth(router, db)
+	controller.UseThumbnail(router, db)
+
+	if err := controller.UseAuth(router, db); err != nil {
+		panic(err)
+	}
+
+	controller.UseSession(router, db)
 	controller.UseLevel(router, db)
+	controller.UsePlay(router, db)
+	controller.UseLeaderboard(router, db)
+	controller.UseJob(router, db)
+	controller.UseUser(router, db)
+	controller.UseAPIKey(router, db)
+	controller.UseBan(router, db)
+	controller.UseIdentity(router, db)
+	controller.UsePrivacy(router, db)
 
 	router.Run("0.0.0.0:3000")
 }
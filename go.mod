@@ -4,6 +4,8 @@ go 1.18
 
 require github.com/appleboy/gin-jwt/v2 v2.9.1
 
+require golang.org/x/image v0.14.0
+
 require (
 	github.com/bytedance/sonic v1.10.2 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20230717121745-296ad89f973d // indirect
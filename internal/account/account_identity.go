@@ -0,0 +1,299 @@
+package account
+
+import (
+	"errors"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/audit"
+	"github.com/Lyretto/spooky-bodies-golang/internal/play"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrMergeConflict = errors.New("identity belongs to another account with platform identities, accounts can't be merged")
+
+// MigrateIdentities creates the identity of every user that logged in before identities existed
+func MigrateIdentities(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO user_identities (user_id, platform_type, platform_user_id, platform_name, created_at)
+		SELECT id, platform_type, platform_user_id, platform_name, created_at FROM users
+		WHERE platform_user_id IS NOT NULL AND platform_user_id != ''
+		ON CONFLICT DO NOTHING
+	`).Error
+}
+
+// MigratePlatformIndex drops the unique index on the platform user id alone. Ids are only unique per
+// platform, anonymous clients choose theirs, so a platform login with the same id must not collide.
+func MigratePlatformIndex(db *gorm.DB) error {
+	return db.Exec("DROP INDEX IF EXISTS idx_platform_id_unique").Error
+}
+
+// FindOrCreateUser resolves the user owning the identity and creates a new account for unknown identities
+func FindOrCreateUser(db *gorm.DB, identity model.UserIdentity) (*model.User, error) {
+	var user model.User
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing model.UserIdentity
+
+		result := tx.Preload("User").
+			Where(&model.UserIdentity{PlatformType: identity.PlatformType, PlatformUserID: identity.PlatformUserID}).
+			Limit(1).
+			Find(&existing)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected > 0 && existing.User != nil {
+			user = *existing.User
+
+			if existing.PlatformName == identity.PlatformName {
+				return nil
+			}
+
+			if err := tx.Model(&existing).Update("platform_name", identity.PlatformName).Error; err != nil {
+				return err
+			}
+
+			// the display name follows the primary identity of the user
+			if user.PlatformType == identity.PlatformType && user.PlatformUserID == identity.PlatformUserID {
+				user.PlatformName = identity.PlatformName
+
+				return tx.Model(&user).Update("platform_name", user.PlatformName).Error
+			}
+
+			return nil
+		}
+
+		user = model.User{
+			PlatformType:   identity.PlatformType,
+			PlatformUserID: identity.PlatformUserID,
+			PlatformName:   identity.PlatformName,
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+
+		return tx.Create(&identity).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func isAnonymous(tx *gorm.DB, user *model.User) (bool, error) {
+	var platformIdentities int64
+
+	err := tx.Model(&model.UserIdentity{}).
+		Where("user_id = ? AND platform_type != ?", user.ID, model.PlatformNone).
+		Count(&platformIdentities).Error
+
+	return platformIdentities == 0, err
+}
+
+// LinkIdentity adds the identity to the user. If the identity already belongs to another account,
+// the anonymous one of both accounts is merged into the other. The surviving account is returned.
+func LinkIdentity(db *gorm.DB, user *model.User, identity model.UserIdentity) (*model.User, error) {
+	survivor := user
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing model.UserIdentity
+
+		result := tx.Preload("User").
+			Where(&model.UserIdentity{PlatformType: identity.PlatformType, PlatformUserID: identity.PlatformUserID}).
+			Limit(1).
+			Find(&existing)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			userIsAnonymous, err := isAnonymous(tx, user)
+
+			if err != nil {
+				return err
+			}
+
+			identity.UserID = user.ID
+
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+
+			// an anonymous account upgraded to a platform account takes over the platform name
+			if userIsAnonymous && identity.PlatformType != model.PlatformNone {
+				user.PlatformType = identity.PlatformType
+				user.PlatformUserID = identity.PlatformUserID
+				user.PlatformName = identity.PlatformName
+
+				return tx.Model(user).Updates(map[string]interface{}{
+					"platform_type":    user.PlatformType,
+					"platform_user_id": user.PlatformUserID,
+					"platform_name":    user.PlatformName,
+				}).Error
+			}
+
+			return nil
+		}
+
+		if existing.UserID == user.ID || existing.User == nil {
+			return nil
+		}
+
+		otherIsAnonymous, err := isAnonymous(tx, existing.User)
+
+		if err != nil {
+			return err
+		}
+
+		if otherIsAnonymous {
+			return Merge(tx, existing.User, user)
+		}
+
+		userIsAnonymous, err := isAnonymous(tx, user)
+
+		if err != nil {
+			return err
+		}
+
+		if !userIsAnonymous {
+			return ErrMergeConflict
+		}
+
+		survivor = existing.User
+
+		return Merge(tx, user, existing.User)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return survivor, nil
+}
+
+// Merge moves everything owned by the account from into the account into and deletes from.
+// Votes and reports that would be duplicates or target own levels after the merge are dropped,
+// as are plays on own levels. Of two scores on a level only the better one is kept.
+func Merge(tx *gorm.DB, from *model.User, into *model.User) error {
+	// levels played by from and levels changing their creator count differently after the merge
+	var playedLevels []uuid.UUID
+
+	err := tx.Model(&model.Play{}).Where("user_id = ?", from.ID).
+		Or("level_id IN (?)", tx.Model(&model.Level{}).Select("id").Where("user_id = ?", from.ID)).
+		Distinct().
+		Pluck("level_id", &playedLevels).Error
+
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&model.Level{}).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error; err != nil {
+		return err
+	}
+
+	for _, table := range []string{(&model.Vote{}).TableName(), (&model.Report{}).TableName()} {
+		err := tx.Exec(`
+			DELETE FROM `+table+` t WHERE t.user_id = ? AND (
+				EXISTS (SELECT 1 FROM `+table+` o WHERE o.user_id = ? AND o.level_id = t.level_id)
+				OR EXISTS (SELECT 1 FROM levels l WHERE l.id = t.level_id AND l.user_id = ?)
+			)`, from.ID, into.ID, into.ID).Error
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Table(table).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	ownLevels := tx.Model(&model.Level{}).Select("id").Where("user_id = ?", into.ID)
+
+	if err := tx.Where("user_id = ? AND level_id IN (?)", from.ID, ownLevels).Delete(&model.Play{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&model.Play{}).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error; err != nil {
+		return err
+	}
+
+	if err := play.ReconcileLevels(tx, playedLevels); err != nil {
+		return err
+	}
+
+	if err := mergeScores(tx, from, into); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&model.UserIdentity{}).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error; err != nil {
+		return err
+	}
+
+	// bans follow the player so merging can't be used to get rid of them
+	if err := tx.Model(&model.Ban{}).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", from.ID).Delete(&model.UserToken{}).Error; err != nil {
+		return err
+	}
+
+	if err := audit.Record(tx, &into.ID, model.AuditSourceAPI, model.AuditAccountMerged, into.ID, map[string]interface{}{
+		"mergedUserId": from.ID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Delete(from).Error
+}
+
+// mergeScores keeps the better score of both accounts per level and status, ranked like the
+// leaderboard with scores of the published version first, and moves the remaining ones to into.
+// Authors have no scores on their own levels, so scores on levels into owns now are dropped.
+func mergeScores(tx *gorm.DB, from *model.User, into *model.User) error {
+	err := tx.Exec(`
+		DELETE FROM scores WHERE id IN (
+			SELECT id FROM (
+				SELECT s.id, ROW_NUMBER() OVER (
+					PARTITION BY s.level_id, s.status
+					ORDER BY s.version = l.published_version DESC, s.score DESC, s.updated_at ASC, s.user_id = @into DESC
+				) AS n
+				FROM scores s JOIN levels l ON l.id = s.level_id
+				WHERE s.user_id IN (@from, @into)
+			) ranked
+			WHERE n > 1
+		)`,
+		map[string]interface{}{"from": from.ID, "into": into.ID},
+	).Error
+
+	if err != nil {
+		return err
+	}
+
+	ownLevels := tx.Model(&model.Level{}).Select("id").Where("user_id = ?", into.ID)
+
+	if err := tx.Where("user_id IN ? AND level_id IN (?)", []uuid.UUID{from.ID, into.ID}, ownLevels).Delete(&model.Score{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&model.Score{}).Where("user_id = ?", from.ID).Update("user_id", into.ID).Error
+}
+
+// IdentityExists tells whether a login with the identity would use an existing account
+func IdentityExists(db *gorm.DB, identity model.UserIdentity) (bool, error) {
+	var count int64
+
+	err := db.Model(&model.UserIdentity{}).
+		Where(&model.UserIdentity{PlatformType: identity.PlatformType, PlatformUserID: identity.PlatformUserID}).
+		Count(&count).Error
+
+	return count > 0, err
+}
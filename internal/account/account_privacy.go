@@ -0,0 +1,248 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNoDeletionRequest = errors.New("no pending deletion request")
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	file, err := archive.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(data)
+}
+
+// Export writes a zip archive with all personal data of the user
+func Export(db *gorm.DB, user *model.User, w io.Writer) error {
+	identities := []model.UserIdentity{}
+	sessions := []model.UserToken{}
+	bans := []model.Ban{}
+	levels := []model.Level{}
+	votes := []model.Vote{}
+	reports := []model.Report{}
+	plays := []model.Play{}
+	scores := []model.Score{}
+
+	queries := []struct {
+		dest  interface{}
+		query *gorm.DB
+	}{
+		{&identities, db.Where("user_id = ?", user.ID)},
+		{&sessions, db.Where("user_id = ?", user.ID)},
+		{&bans, db.Where("user_id = ?", user.ID)},
+		{&levels, db.Where("user_id = ?", user.ID)},
+		{&votes, db.Where("user_id = ?", user.ID)},
+		{&reports, db.Where("user_id = ?", user.ID)},
+		{&plays, db.Where("user_id = ?", user.ID)},
+		{&scores, db.Where("user_id = ?", user.ID)},
+	}
+
+	for _, q := range queries {
+		if err := q.query.Find(q.dest).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := levelcontent.LoadLevels(levels); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", map[string]interface{}{
+			"user":       user,
+			"role":       user.Role,
+			"identities": identities,
+			"sessions":   sessions,
+			"bans":       bans,
+		}},
+		{"levels.json", levels},
+		{"votes.json", votes},
+		{"reports.json", reports},
+		{"plays.json", plays},
+		{"scores.json", scores},
+	}
+
+	for _, f := range files {
+		if err := writeJSON(archive, f.name, f.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// FindDeletionRequest returns the pending deletion request of the user or nil
+func FindDeletionRequest(db *gorm.DB, userID uuid.UUID) (*model.DeletionRequest, error) {
+	var request model.DeletionRequest
+
+	tx := db.Where("user_id = ? AND completed_at is null AND cancelled_at is null", userID).Limit(1).Find(&request)
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &request, nil
+}
+
+// RequestDeletion schedules the deletion of the account after the configured grace period
+func RequestDeletion(db *gorm.DB, user *model.User) (*model.DeletionRequest, error) {
+	request, err := FindDeletionRequest(db, user.ID)
+
+	if err != nil || request != nil {
+		return request, err
+	}
+
+	now := time.Now()
+
+	request = &model.DeletionRequest{
+		UserID:       user.ID,
+		Policy:       config.C.Privacy.DeletionPolicy,
+		RequestedAt:  now,
+		ExecuteAfter: now.AddDate(0, 0, config.C.Privacy.DeletionGraceDays),
+	}
+
+	if err := db.Create(request).Error; err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func CancelDeletion(db *gorm.DB, userID uuid.UUID) error {
+	tx := db.Model(&model.DeletionRequest{}).
+		Where("user_id = ? AND completed_at is null AND cancelled_at is null", userID).
+		Update("cancelled_at", time.Now())
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrNoDeletionRequest
+	}
+
+	return nil
+}
+
+// removeCredentials deletes everything the user could log in or be recognised with
+func removeCredentials(tx *gorm.DB, userID uuid.UUID) error {
+	for _, m := range []interface{}{&model.UserIdentity{}, &model.UserToken{}, &model.APIKey{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func anonymise(tx *gorm.DB, userID uuid.UUID) error {
+	if err := removeCredentials(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&model.Report{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"platform_type":    model.PlatformNone,
+		"platform_user_id": "deleted:" + userID.String(),
+		"platform_name":    "deleted user",
+		"role":             model.UserRolePlayer,
+	}).Error
+}
+
+func deleteAccount(tx *gorm.DB, userID uuid.UUID) error {
+	if err := removeCredentials(tx, userID); err != nil {
+		return err
+	}
+
+	ownLevels := tx.Model(&model.Level{}).Select("id").Where("user_id = ?", userID)
+
+	for _, m := range []interface{}{&model.Vote{}, &model.Report{}, &model.Play{}, &model.Score{}} {
+		if err := tx.Where("user_id = ? OR level_id IN (?)", userID, ownLevels).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, m := range []interface{}{&model.LevelRevision{}, &model.Thumbnail{}} {
+		if err := tx.Where("level_id IN (?)", ownLevels).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, m := range []interface{}{&model.Level{}, &model.Ban{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("id = ?", userID).Delete(&model.User{}).Error
+}
+
+// ExecuteDeletions carries out all deletion requests past their grace period
+func ExecuteDeletions(tx *gorm.DB) error {
+	requests := []model.DeletionRequest{}
+
+	err := tx.
+		Where("completed_at is null AND cancelled_at is null AND execute_after < ?", time.Now()).
+		Find(&requests).Error
+
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			var err error
+
+			switch request.Policy {
+			case model.DeletionPolicyDelete:
+				err = deleteAccount(tx, request.UserID)
+			case model.DeletionPolicyAnonymise:
+				err = anonymise(tx, request.UserID)
+			default:
+				err = fmt.Errorf("unknown deletion policy %s", request.Policy)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			return tx.Model(&request).Update("completed_at", time.Now()).Error
+		})
+
+		if err != nil {
+			return fmt.Errorf("deletion request %s: %w", request.ID, err)
+		}
+	}
+
+	return nil
+}
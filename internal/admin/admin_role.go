@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/audit"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRole = errors.New("invalid role")
+var ErrUserNotFound = errors.New("user not found")
+
+func IsValidRole(role model.UserRole) bool {
+	switch role {
+	case model.UserRolePlayer, model.UserRoleMod, model.UserRoleAgent, model.UserRoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetUserRole changes the role of a user and records the change in the audit trail
+func SetUserRole(db *gorm.DB, userID uuid.UUID, role model.UserRole, actorID *uuid.UUID, source model.AuditSource, reason string) (*model.User, error) {
+	if !IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	var user model.User
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", userID).Limit(1).Find(&user)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		previousRole := user.Role
+
+		if previousRole == role {
+			return nil
+		}
+
+		if err := tx.Model(&user).Update("role", role).Error; err != nil {
+			return err
+		}
+
+		return audit.Record(tx, actorID, source, model.AuditRoleChanged, user.ID, map[string]interface{}{
+			"from":   previousRole,
+			"to":     role,
+			"reason": reason,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
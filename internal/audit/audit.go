@@ -0,0 +1,18 @@
+package audit
+
+import (
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Record writes an audit entry, pass the transaction of the audited change so both commit together
+func Record(tx *gorm.DB, actorID *uuid.UUID, source model.AuditSource, action model.AuditAction, targetID uuid.UUID, details map[string]interface{}) error {
+	return tx.Create(&model.AuditEntry{
+		ActorID:  actorID,
+		Source:   source,
+		Action:   action,
+		TargetID: targetID,
+		Details:  details,
+	}).Error
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyInvalid = errors.New("api key invalid")
+
+const apiKeyPrefix = "sbk_"
+const apiKeyHeader = "X-API-Key"
+const apiKeyAuthScheme = "ApiKey "
+
+var apiKeyContextKey = "apiKey"
+
+// api keys are touched at most once per interval to not write on every agent request
+const apiKeyTouchInterval = time.Minute
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey issues a new key for the user. The plain key is only returned here and never stored.
+func CreateAPIKey(db *gorm.DB, userID uuid.UUID, createdByID uuid.UUID, name string, scopes []model.APIScope, expiresAt *time.Time) (*model.APIKey, string, error) {
+	secret := make([]byte, 32)
+
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := model.APIKey{
+		Name:        name,
+		Prefix:      key[:len(apiKeyPrefix)+8],
+		KeyHash:     hashAPIKey(key),
+		Scopes:      scopes,
+		UserID:      userID,
+		CreatedByID: createdByID,
+		ExpiresAt:   expiresAt,
+	}
+
+	if err := db.Create(&apiKey).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &apiKey, key, nil
+}
+
+// requestAPIKey reads the key from the X-API-Key header or an "Authorization: ApiKey <key>" header
+func requestAPIKey(context *gin.Context) string {
+	if key := context.GetHeader(apiKeyHeader); key != "" {
+		return key
+	}
+
+	if authorization := context.GetHeader("Authorization"); strings.HasPrefix(authorization, apiKeyAuthScheme) {
+		return strings.TrimPrefix(authorization, apiKeyAuthScheme)
+	}
+
+	return ""
+}
+
+// HasAPIKey tells whether the request tries to authenticate with an api key instead of a jwt
+func HasAPIKey(context *gin.Context) bool {
+	return requestAPIKey(context) != ""
+}
+
+// GetAPIKey returns the api key the request was authenticated with, nil for jwt authenticated requests
+func GetAPIKey(context *gin.Context) *model.APIKey {
+	k, exists := context.Get(apiKeyContextKey)
+
+	if !exists {
+		return nil
+	}
+
+	apiKey, ok := k.(*model.APIKey)
+
+	if !ok {
+		return nil
+	}
+
+	return apiKey
+}
+
+// CheckAPIKey authenticates the request by its api key and makes the owning user available like a jwt identity
+func CheckAPIKey(context *gin.Context, db *gorm.DB) (*model.APIKey, error) {
+	key := requestAPIKey(context)
+
+	var apiKey model.APIKey
+
+	tx := db.Preload("User").Where("key_hash = ?", hashAPIKey(key)).Limit(1).Find(&apiKey)
+
+	if tx.Error != nil {
+		context.AbortWithStatus(http.StatusUnauthorized)
+		return nil, tx.Error
+	}
+
+	now := time.Now()
+
+	if tx.RowsAffected == 0 ||
+		apiKey.User == nil ||
+		apiKey.RevokedAt != nil ||
+		(apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now)) {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrAPIKeyInvalid.Error()})
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		apiKey.LastUsedAt = &now
+
+		if err := db.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+			return &apiKey, err
+		}
+	}
+
+	context.Set(jwtIdentityKey, apiKey.User)
+	context.Set(apiKeyContextKey, &apiKey)
+
+	return &apiKey, nil
+}
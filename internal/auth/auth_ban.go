@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BannedError is returned when a banned user tries to log in
+type BannedError struct {
+	Ban *model.Ban
+}
+
+func (e *BannedError) Error() string {
+	return fmt.Sprintf("user is banned (%s)", e.Ban.Scope)
+}
+
+// FindActiveBan returns the active ban of the user covering one of the scopes, a full ban covers all scopes
+func FindActiveBan(db *gorm.DB, userID uuid.UUID, scopes ...model.BanScope) (*model.Ban, error) {
+	var ban model.Ban
+
+	tx := db.
+		Where("user_id = ? AND lifted_at is null AND (expires_at is null OR expires_at > ?)", userID, time.Now()).
+		Where("scope IN ?", append(scopes, model.BanScopeFull)).
+		Order("expires_at desc nulls first").
+		Limit(1).
+		Find(&ban)
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &ban, nil
+}
+
+// BanResponse is the payload shown by the game client when an action is blocked by a ban
+func BanResponse(ban *model.Ban) gin.H {
+	return gin.H{
+		"error": "banned",
+		"ban": gin.H{
+			"id":        ban.ID,
+			"scope":     ban.Scope,
+			"reason":    ban.Reason,
+			"expiresAt": ban.ExpiresAt,
+		},
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes a rsa or ed25519 public key, other keys can't be published
+func NewJWK(kid string, alg string, key interface{}) (JWK, bool) {
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: alg,
+			N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: alg,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// PublicKey decodes the key material of the JWK into a *rsa.PublicKey or an ed25519.PublicKey
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// jwksKeySet caches the keys of a remote or local JWKS document. Unknown key ids trigger a reload
+// so keys rotated by the issuer are picked up without a restart.
+type jwksKeySet struct {
+	url      string
+	file     string
+	maxAge   time.Duration
+	mutex    sync.Mutex
+	keys     map[string]interface{}
+	loadedAt time.Time
+}
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// jwksMinReload limits how often unknown key ids can force a reload of the key set
+const jwksMinReload = time.Minute
+
+func newJWKSKeySet(url string, file string) *jwksKeySet {
+	return &jwksKeySet{
+		url:    url,
+		file:   file,
+		maxAge: time.Hour,
+	}
+}
+
+func (s *jwksKeySet) read() ([]byte, error) {
+	if s.file != "" {
+		return os.ReadFile(s.file)
+	}
+
+	if s.url == "" {
+		return nil, errors.New("no jwks source configured")
+	}
+
+	resp, err := jwksClient.Get(s.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint responded with %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (s *jwksKeySet) load() error {
+	raw, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	var set JWKS
+
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return err
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+
+		if err != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	s.keys = keys
+	s.loadedAt = time.Now()
+
+	return nil
+}
+
+// Key returns the public key for the given key id
+func (s *jwksKeySet) Key(kid string) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keys == nil || time.Since(s.loadedAt) > s.maxAge {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(s.loadedAt) < jwksMinReload {
+		return nil, ErrUnknownKeyID
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKeyID
+}
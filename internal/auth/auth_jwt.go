@@ -1,24 +1,28 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/Lyretto/spooky-bodies-golang/internal/account"
 	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/ratelimit"
 	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-type loginParams struct {
-	PlatformType   model.PlatformType `json:"platformType"`
-	PlatformUserID string             `json:"platformUserId"`
-}
-
 var jwtIdentityKey = "userId"
+var userTokenKey = "userToken"
+var deviceLabelKey = "deviceLabel"
+
+// sessions are only touched once in a while to not write on every request
+const sessionTouchInterval = time.Minute
+
+const maxDeviceLabelLength = 128
 
 func GetJWTUser(ctx *gin.Context) *model.User {
 	u, exists := ctx.Get(jwtIdentityKey)
@@ -36,6 +40,35 @@ func GetJWTUser(ctx *gin.Context) *model.User {
 	return user
 }
 
+// GetUserToken returns the session of the current request
+func GetUserToken(ctx *gin.Context) *model.UserToken {
+	t, exists := ctx.Get(userTokenKey)
+
+	if !exists {
+		return nil
+	}
+
+	userToken, ok := t.(*model.UserToken)
+
+	if !ok {
+		return nil
+	}
+
+	return userToken
+}
+
+func deviceLabel(c *gin.Context, label string) string {
+	if label == "" {
+		label = c.Request.UserAgent()
+	}
+
+	if len(label) > maxDeviceLabelLength {
+		label = label[:maxDeviceLabelLength]
+	}
+
+	return label
+}
+
 func CheckTokenActivity(context *gin.Context, db *gorm.DB) (bool, *model.UserToken, error) {
 	token := jwt.GetToken(context)
 
@@ -52,63 +85,161 @@ func CheckTokenActivity(context *gin.Context, db *gorm.DB) (bool, *model.UserTok
 		return false, nil, tx.Error
 	}
 
+	// the token was logged out or its session revoked
+	if tx.RowsAffected == 0 {
+		context.AbortWithStatus(http.StatusUnauthorized)
+		return false, nil, nil
+	}
+
+	if time.Since(userToken.LastUsedAt) > sessionTouchInterval {
+		userToken.LastUsedAt = time.Now()
+
+		if err := db.Model(&userToken).UpdateColumn("last_used_at", userToken.LastUsedAt).Error; err != nil {
+			return true, &userToken, err
+		}
+	}
+
+	context.Set(userTokenKey, &userToken)
+
 	return true, &userToken, nil
 }
 
+// keyRing signs and verifies all access tokens, it is loaded with the middleware
+var keyRing *KeyRing
+
+// GenerateToken issues an access token signed by the active key of the key ring
+func GenerateToken(mw *jwt.GinJWTMiddleware, data interface{}) (string, time.Time, error) {
+	return keyRing.GenerateToken(mw, data)
+}
+
+// LoginHandler replaces the login handler of the lib, which signs tokens without a kid header
+func LoginHandler(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := limiters.ip.Allow(c.ClientIP()); err != nil {
+			var limitedErr *ratelimit.LimitedError
+
+			if errors.As(err, &limitedErr) {
+				ratelimit.Abort(c, limitedErr)
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		data, err := mw.Authenticator(c)
+
+		var bannedErr *BannedError
+		var limitedErr *ratelimit.LimitedError
+
+		if errors.As(err, &bannedErr) {
+			c.JSON(http.StatusForbidden, BanResponse(bannedErr.Ban))
+			return
+		}
+
+		if errors.As(err, &limitedErr) {
+			ratelimit.Abort(c, limitedErr)
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, expire, err := GenerateToken(mw, data)
+
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": jwt.ErrFailedTokenCreation.Error()})
+			return
+		}
+
+		mw.LoginResponse(c, http.StatusOK, token, expire)
+	}
+}
+
+// JWKSHandler publishes the public keys so validation agents can verify tokens offline
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keyRing.JWKS())
+}
+
 func GetJWTMiddleware(db *gorm.DB) (*jwt.GinJWTMiddleware, error) {
-	// this lib doesn't provide a proper refresh token. this is alright for now but it should be replaced
+	ring, err := LoadKeyRing()
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyRing = ring
+
+	loadLoginLimiters()
+
+	// access tokens are short lived and not refreshable through the lib, sessions are kept alive
+	// with the rotating refresh token persisted on the user token instead (see RotateRefreshToken)
 	return jwt.New(&jwt.GinJWTMiddleware{
 		Realm:            "spooky-bodies",
-		SigningAlgorithm: "HS512",
-		Key:              []byte(config.C.JWTKey),
-		Timeout:          time.Hour,
-		MaxRefresh:       time.Hour,
+		SigningAlgorithm: ring.active.method.Alg(),
+		KeyFunc:          ring.KeyFunc,
+		Timeout:          time.Minute * time.Duration(config.C.TokenLifeSpan),
 		IdentityKey:      jwtIdentityKey,
 		TokenLookup:      "header: Authorization",
 		Authenticator: func(c *gin.Context) (interface{}, error) {
-			var loginParams loginParams
+			var loginParams LoginParams
 
 			if err := c.Bind(&loginParams); err != nil {
 				return "", jwt.ErrMissingLoginValues
 			}
 
-			var user model.User
+			identity, err := VerifyPlatformIdentity(loginParams)
 
-			tx := db.Where(&model.User{PlatformType: loginParams.PlatformType, PlatformUserID: loginParams.PlatformUserID}).Limit(1).Find(&user)
+			if err != nil {
+				if errors.Is(err, jwt.ErrMissingLoginValues) {
+					return nil, err
+				}
 
-			if tx.Error != nil {
-				return nil, tx.Error
+				return nil, jwt.ErrFailedAuthentication
 			}
 
-			switch loginParams.PlatformType {
-			case model.PlatformSteam:
-				return nil, jwt.ErrFailedAuthentication
-			case model.PlatformNintendo:
-				return nil, jwt.ErrFailedAuthentication
-			case model.PlatformNone:
-				if tx.RowsAffected == 0 {
-					user = model.User{
-						PlatformType:   loginParams.PlatformType,
-						PlatformUserID: loginParams.PlatformUserID,
-						PlatformName:   "anonym",
-					}
+			if err := limiters.platformID.Allow(identity.PlatformType + ":" + identity.PlatformUserID); err != nil {
+				return nil, err
+			}
 
-					tx = db.Where("platform_type = ?", loginParams.PlatformType).Clauses(clause.OnConflict{
-						Columns:   []clause.Column{{Name: "platform_user_id"}},
-						DoNothing: true,
-					}).Create(&user)
+			// anonymous accounts cost nothing to create, so their creation is capped per ip
+			if identity.PlatformType == model.PlatformNone {
+				exists, err := account.IdentityExists(db, identity.UserIdentity())
 
-					if tx.Error != nil {
-						return nil, tx.Error
+				if err != nil {
+					return nil, err
+				}
+
+				if !exists {
+					if err := limiters.anonymousAccounts.Allow(c.ClientIP()); err != nil {
+						return nil, err
 					}
 				}
+			}
 
-				c.Set("user", &user)
-			default:
-				return nil, jwt.ErrFailedAuthentication
+			user, err := account.FindOrCreateUser(db, identity.UserIdentity())
+
+			if err != nil {
+				return nil, err
 			}
 
-			return &user, nil
+			ban, err := FindActiveBan(db, user.ID)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if ban != nil {
+				return nil, &BannedError{Ban: ban}
+			}
+
+			c.Set("user", user)
+			c.Set(deviceLabelKey, deviceLabel(c, loginParams.DeviceLabel))
+
+			return user, nil
 		},
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if v, ok := data.(*model.User); ok {
@@ -119,25 +250,6 @@ func GetJWTMiddleware(db *gorm.DB) (*jwt.GinJWTMiddleware, error) {
 
 			return jwt.MapClaims{}
 		},
-		RefreshResponse: func(c *gin.Context, code int, jwtToken string, validUntil time.Time) {
-			_, userToken, _ := CheckTokenActivity(c, db)
-
-			if userToken == nil {
-				return
-			}
-
-			userToken.Token = jwtToken
-			userToken.ValidUntil = validUntil
-
-			if err := db.Save(userToken).Error; err != nil {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-
-			c.JSON(code, gin.H{
-				"token": jwtToken,
-			})
-		},
 		LoginResponse: func(c *gin.Context, code int, jwtToken string, validUntil time.Time) {
 			user, userExists := c.Get("user")
 
@@ -158,12 +270,24 @@ func GetJWTMiddleware(db *gorm.DB) (*jwt.GinJWTMiddleware, error) {
 			}
 
 			userToken := model.UserToken{
-				User:       u,
-				Token:      jwtToken,
-				ValidUntil: validUntil,
+				User:        u,
+				Token:       jwtToken,
+				ValidUntil:  validUntil,
+				DeviceLabel: c.GetString(deviceLabelKey),
+				Platform:    u.PlatformType,
+				LastUsedAt:  time.Now(),
+			}
+
+			refreshToken, err := IssueRefreshToken(&userToken)
+
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "refresh token could not be created",
+				})
+				return
 			}
 
-			if err := db.Save(&userToken).Error; err != nil {
+			if err := db.Create(&userToken).Error; err != nil {
 				c.JSON(http.StatusUnauthorized, gin.H{
 					"error": "token could not be persisted",
 				})
@@ -171,7 +295,9 @@ func GetJWTMiddleware(db *gorm.DB) (*jwt.GinJWTMiddleware, error) {
 			}
 
 			c.JSON(code, gin.H{
-				"token": jwtToken,
+				"token":        jwtToken,
+				"expire":       validUntil,
+				"refreshToken": refreshToken,
 			})
 		},
 		IdentityHandler: func(c *gin.Context) interface{} {
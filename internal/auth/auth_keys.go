@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+// legacyKeyID is used for the single JWTKey of older configs
+const legacyKeyID = "default"
+
+var ErrNoSigningKey = errors.New("no active jwt signing key configured")
+
+type signingKey struct {
+	id        string
+	method    gojwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyRing holds the active signing key and all keys tokens are still accepted with.
+// Verification only keys allow rotating the signing key without logging out every player.
+type KeyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+func loadSigningKey(keyConfig config.JWTSigningKey) (*signingKey, error) {
+	method := gojwt.GetSigningMethod(keyConfig.Algorithm)
+
+	if method == nil {
+		return nil, fmt.Errorf("jwt key %s: unsupported algorithm %s", keyConfig.ID, keyConfig.Algorithm)
+	}
+
+	key := signingKey{
+		id:     keyConfig.ID,
+		method: method,
+	}
+
+	switch method.(type) {
+	case *gojwt.SigningMethodHMAC:
+		if keyConfig.Secret == "" {
+			return nil, fmt.Errorf("jwt key %s: missing secret", keyConfig.ID)
+		}
+
+		key.signKey = []byte(keyConfig.Secret)
+		key.verifyKey = key.signKey
+	case *gojwt.SigningMethodRSA, *gojwt.SigningMethodEd25519:
+		if keyConfig.PrivateKeyFile != "" {
+			raw, err := os.ReadFile(keyConfig.PrivateKeyFile)
+
+			if err != nil {
+				return nil, err
+			}
+
+			var privateKey crypto.Signer
+
+			if _, ok := method.(*gojwt.SigningMethodRSA); ok {
+				privateKey, err = gojwt.ParseRSAPrivateKeyFromPEM(raw)
+			} else {
+				var edKey crypto.PrivateKey
+
+				edKey, err = gojwt.ParseEdPrivateKeyFromPEM(raw)
+
+				if err == nil {
+					privateKey = edKey.(ed25519.PrivateKey)
+				}
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %s: %w", keyConfig.ID, err)
+			}
+
+			key.signKey = privateKey
+			key.verifyKey = privateKey.Public()
+		}
+
+		if key.verifyKey == nil && keyConfig.PublicKeyFile != "" {
+			raw, err := os.ReadFile(keyConfig.PublicKeyFile)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := method.(*gojwt.SigningMethodRSA); ok {
+				key.verifyKey, err = gojwt.ParseRSAPublicKeyFromPEM(raw)
+			} else {
+				key.verifyKey, err = gojwt.ParseEdPublicKeyFromPEM(raw)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %s: %w", keyConfig.ID, err)
+			}
+		}
+
+		if key.verifyKey == nil {
+			return nil, fmt.Errorf("jwt key %s: missing key file", keyConfig.ID)
+		}
+	default:
+		return nil, fmt.Errorf("jwt key %s: unsupported algorithm %s", keyConfig.ID, keyConfig.Algorithm)
+	}
+
+	return &key, nil
+}
+
+// LoadKeyRing reads the configured jwt keys. Configs with only the legacy JWTKey are treated
+// as a single HS512 key, which stays valid for tokens issued without a kid.
+func LoadKeyRing() (*KeyRing, error) {
+	keyConfigs := config.C.JWTKeys
+	activeID := config.C.JWTActiveKeyID
+
+	if config.C.JWTKey != "" {
+		keyConfigs = append(keyConfigs, config.JWTSigningKey{
+			ID:        legacyKeyID,
+			Algorithm: "HS512",
+			Secret:    config.C.JWTKey,
+		})
+
+		if activeID == "" {
+			activeID = legacyKeyID
+		}
+	}
+
+	ring := KeyRing{
+		keys: map[string]*signingKey{},
+	}
+
+	for _, keyConfig := range keyConfigs {
+		if _, exists := ring.keys[keyConfig.ID]; exists {
+			return nil, fmt.Errorf("jwt key %s is configured twice", keyConfig.ID)
+		}
+
+		key, err := loadSigningKey(keyConfig)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ring.keys[key.id] = key
+	}
+
+	active, ok := ring.keys[activeID]
+
+	if !ok || active.signKey == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	ring.active = active
+
+	return &ring, nil
+}
+
+// Sign signs the claims with the active key and names it in the kid header
+func (k *KeyRing) Sign(claims gojwt.MapClaims) (string, error) {
+	token := gojwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+
+	return token.SignedString(k.active.signKey)
+}
+
+// KeyFunc resolves the verification key by the kid header of the token
+func (k *KeyRing) KeyFunc(token *gojwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	if kid == "" {
+		kid = legacyKeyID
+	}
+
+	key, ok := k.keys[kid]
+
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrInvalidSigningAlgorithm
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWKS publishes the public keys of all asymmetric keys, symmetric keys are never published
+func (k *KeyRing) JWKS() JWKS {
+	set := JWKS{
+		Keys: []JWK{},
+	}
+
+	for _, key := range k.keys {
+		if jwk, ok := NewJWK(key.id, key.method.Alg(), key.verifyKey); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+// GenerateToken creates a signed access token for the user data with the claims of the middleware
+func (k *KeyRing) GenerateToken(mw *jwt.GinJWTMiddleware, data interface{}) (string, time.Time, error) {
+	claims := gojwt.MapClaims{}
+
+	if mw.PayloadFunc != nil {
+		for key, value := range mw.PayloadFunc(data) {
+			claims[key] = value
+		}
+	}
+
+	expire := mw.TimeFunc().Add(mw.Timeout)
+	claims["exp"] = expire.Unix()
+	claims["orig_iat"] = mw.TimeFunc().Unix()
+
+	token, err := k.Sign(claims)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expire, nil
+}
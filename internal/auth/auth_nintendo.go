@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+var ErrNintendoTokenInvalid = errors.New("nintendo id token invalid")
+
+type nintendoVerifier struct {
+	once sync.Once
+	keys *jwksKeySet
+}
+
+// keySet is created lazily as the config is not loaded yet when the verifiers are registered
+func (v *nintendoVerifier) keySet() *jwksKeySet {
+	v.once.Do(func() {
+		v.keys = newJWKSKeySet(config.C.Nintendo.JWKSURL, config.C.Nintendo.JWKSFile)
+	})
+
+	return v.keys
+}
+
+// Verify validates the ID token issued by the nintendo account server. The signature is checked
+// against the configured JWKS, audience and expiry must match.
+func (v *nintendoVerifier) Verify(params LoginParams) (*PlatformIdentity, error) {
+	if params.IDToken == "" {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	claims := gojwt.MapClaims{}
+
+	_, err := gojwt.ParseWithClaims(params.IDToken, claims, func(token *gojwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		return v.keySet().Key(kid)
+	}, gojwt.WithValidMethods([]string{"RS256"}))
+
+	if err != nil {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	if !claims.VerifyAudience(config.C.Nintendo.Audience, true) {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	if config.C.Nintendo.Issuer != "" && !claims.VerifyIssuer(config.C.Nintendo.Issuer, true) {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	// Valid() only checks exp when it is set, an ID token without expiry is not acceptable
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	if subject == "" {
+		return nil, ErrNintendoTokenInvalid
+	}
+
+	name, _ := claims["nickname"].(string)
+
+	if name == "" {
+		name = "nintendo"
+	}
+
+	return &PlatformIdentity{
+		PlatformType:   model.PlatformNintendo,
+		PlatformUserID: subject,
+		PlatformName:   name,
+	}, nil
+}
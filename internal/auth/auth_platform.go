@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	jwt "github.com/appleboy/gin-jwt/v2"
+)
+
+type LoginParams struct {
+	PlatformType   model.PlatformType `json:"platformType"`
+	PlatformUserID string             `json:"platformUserId"`
+	// hex encoded auth session ticket, only used by steam logins
+	Ticket string `json:"ticket"`
+	// ID token issued by the nintendo account server, only used by nintendo logins
+	IDToken string `json:"idToken"`
+	// DeviceLabel is shown in the session list, defaults to the user agent
+	DeviceLabel string `json:"deviceLabel"`
+}
+
+// PlatformIdentity is a verified identity of a player on one of the supported platforms
+type PlatformIdentity struct {
+	PlatformType   model.PlatformType
+	PlatformUserID string
+	PlatformName   string
+}
+
+func (i *PlatformIdentity) UserIdentity() model.UserIdentity {
+	return model.UserIdentity{
+		PlatformType:   i.PlatformType,
+		PlatformUserID: i.PlatformUserID,
+		PlatformName:   i.PlatformName,
+	}
+}
+
+// PlatformVerifier checks the platform specific proof of a login and resolves the identity behind it
+type PlatformVerifier interface {
+	Verify(params LoginParams) (*PlatformIdentity, error)
+}
+
+var platformVerifiers = map[model.PlatformType]PlatformVerifier{
+	model.PlatformNone:     &anonymousVerifier{},
+	model.PlatformSteam:    &steamVerifier{},
+	model.PlatformNintendo: &nintendoVerifier{},
+}
+
+// RegisterPlatformVerifier replaces the verifier used for logins of the given platform
+func RegisterPlatformVerifier(platformType model.PlatformType, verifier PlatformVerifier) {
+	platformVerifiers[platformType] = verifier
+}
+
+// VerifyPlatformIdentity resolves the identity for the login params with the verifier of its platform
+func VerifyPlatformIdentity(params LoginParams) (*PlatformIdentity, error) {
+	verifier, ok := platformVerifiers[params.PlatformType]
+
+	if !ok {
+		return nil, jwt.ErrFailedAuthentication
+	}
+
+	identity, err := verifier.Verify(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.PlatformUserID == "" {
+		return nil, jwt.ErrMissingLoginValues
+	}
+
+	return identity, nil
+}
+
+type anonymousVerifier struct{}
+
+// Verify trusts the client generated id, anonymous accounts are bound to the device only
+func (v *anonymousVerifier) Verify(params LoginParams) (*PlatformIdentity, error) {
+	return &PlatformIdentity{
+		PlatformType:   model.PlatformNone,
+		PlatformUserID: params.PlatformUserID,
+		PlatformName:   "anonym",
+	}, nil
+}
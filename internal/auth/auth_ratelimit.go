@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/ratelimit"
+)
+
+var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+
+// UseRateLimitStore replaces the in-memory store of the login limits, it has to be called before GetJWTMiddleware
+func UseRateLimitStore(store ratelimit.Store) {
+	rateLimitStore = store
+}
+
+type loginLimiters struct {
+	ip                *ratelimit.Limiter
+	platformID        *ratelimit.Limiter
+	anonymousAccounts *ratelimit.Limiter
+}
+
+var limiters loginLimiters
+
+func loadLoginLimiters() {
+	limits := config.C.RateLimit
+
+	limiters = loginLimiters{
+		ip:                ratelimit.NewLimiter(rateLimitStore, "login-ip", ratelimit.Per(limits.LoginPerIPPerMinute, time.Minute)),
+		platformID:        ratelimit.NewLimiter(rateLimitStore, "login-platform-id", ratelimit.Per(limits.LoginPerPlatformIDPerMinute, time.Minute)),
+		anonymousAccounts: ratelimit.NewLimiter(rateLimitStore, "anonymous-accounts", ratelimit.Per(limits.AnonymousAccountsPerIPPerDay, 24*time.Hour)),
+	}
+}
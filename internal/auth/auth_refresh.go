@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+var ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken sets a fresh refresh token on the user token and returns its plain text form.
+// The token is prefixed with the id of the user token so every rotation stays in the same session,
+// which makes the session the token family revoked on reuse.
+func IssueRefreshToken(userToken *model.UserToken) (string, error) {
+	if userToken.ID == uuid.Nil {
+		userToken.ID = uuid.New()
+	}
+
+	secret := make([]byte, 32)
+
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	userToken.RefreshTokenHash = hashRefreshSecret(encodedSecret)
+	userToken.RefreshValidUntil = time.Now().Add(time.Hour * time.Duration(config.C.RefreshTokenLifeSpan))
+
+	return userToken.ID.String() + "." + encodedSecret, nil
+}
+
+func revokeSession(db *gorm.DB, userTokenID uuid.UUID) error {
+	return db.Where("id = ?", userTokenID).Delete(&model.UserToken{}).Error
+}
+
+// RotateRefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Presenting an already rotated refresh token revokes the whole session.
+func RotateRefreshToken(db *gorm.DB, mw *jwt.GinJWTMiddleware, refreshToken string) (*model.UserToken, string, error) {
+	id, secret, found := strings.Cut(refreshToken, ".")
+
+	if !found || secret == "" {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	userTokenID, err := uuid.Parse(id)
+
+	if err != nil {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	var userToken model.UserToken
+
+	tx := db.Preload("User").Where("id = ?", userTokenID).Limit(1).Find(&userToken)
+
+	if tx.Error != nil {
+		return nil, "", tx.Error
+	}
+
+	if tx.RowsAffected == 0 || userToken.User == nil || userToken.RefreshTokenHash == "" {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	previousHash := userToken.RefreshTokenHash
+
+	if subtle.ConstantTimeCompare([]byte(previousHash), []byte(hashRefreshSecret(secret))) != 1 {
+		if err := revokeSession(db, userToken.ID); err != nil {
+			return nil, "", err
+		}
+
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	if userToken.RefreshValidUntil.Before(time.Now()) {
+		if err := revokeSession(db, userToken.ID); err != nil {
+			return nil, "", err
+		}
+
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	accessToken, validUntil, err := GenerateToken(mw, userToken.User)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	newRefreshToken, err := IssueRefreshToken(&userToken)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	userToken.Token = accessToken
+	userToken.ValidUntil = validUntil
+	userToken.LastUsedAt = time.Now()
+
+	// only rotate if nobody else rotated in the meantime, a concurrent use of the same token is a reuse
+	tx = db.Model(&model.UserToken{}).
+		Where("id = ? AND refresh_token_hash = ?", userToken.ID, previousHash).
+		Updates(map[string]interface{}{
+			"token":               userToken.Token,
+			"valid_until":         userToken.ValidUntil,
+			"refresh_token_hash":  userToken.RefreshTokenHash,
+			"refresh_valid_until": userToken.RefreshValidUntil,
+			"last_used_at":        userToken.LastUsedAt,
+		})
+
+	if tx.Error != nil {
+		return nil, "", tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		if err := revokeSession(db, userToken.ID); err != nil {
+			return nil, "", err
+		}
+
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	return &userToken, newRefreshToken, nil
+}
@@ -0,0 +1,13 @@
+package auth
+
+import "github.com/Lyretto/spooky-bodies-golang/pkg/model"
+
+// IsModerator tells whether the user moderates players and levels, admins can do everything moderators can
+func IsModerator(user *model.User) bool {
+	return user.Role == model.UserRoleMod || user.Role == model.UserRoleAdmin
+}
+
+// IsReviewer tells whether the user may see and validate levels under review, which validation agents also do
+func IsReviewer(user *model.User) bool {
+	return IsModerator(user) || user.Role == model.UserRoleAgent
+}
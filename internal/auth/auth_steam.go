@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+)
+
+var ErrSteamTicketInvalid = errors.New("steam ticket invalid")
+var ErrSteamPublisherBanned = errors.New("steam account is publisher banned")
+
+type SteamPlayer struct {
+	SteamID     string
+	PersonaName string
+}
+
+type steamAuthenticateResponse struct {
+	Response struct {
+		Params *struct {
+			Result          string `json:"result"`
+			SteamID         string `json:"steamid"`
+			OwnerSteamID    string `json:"ownersteamid"`
+			VACBanned       bool   `json:"vacbanned"`
+			PublisherBanned bool   `json:"publisherbanned"`
+		} `json:"params"`
+		Error *struct {
+			ErrorCode int    `json:"errorcode"`
+			ErrorDesc string `json:"errordesc"`
+		} `json:"error"`
+	} `json:"response"`
+}
+
+type steamPlayerSummariesResponse struct {
+	Response struct {
+		Players []struct {
+			SteamID     string `json:"steamid"`
+			PersonaName string `json:"personaname"`
+		} `json:"players"`
+	} `json:"response"`
+}
+
+var steamClient = &http.Client{Timeout: 10 * time.Second}
+
+func steamGet(path string, query url.Values, out interface{}) error {
+	endpoint := strings.TrimRight(config.C.Steam.APIURL, "/") + path + "?" + query.Encode()
+
+	resp, err := steamClient.Get(endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("steam web api %s responded with %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// VerifySteamTicket validates an auth session ticket issued to the game client
+// against the Steam Web API and resolves the owning player including the
+// current persona name.
+func VerifySteamTicket(ticket string) (*SteamPlayer, error) {
+	if ticket == "" {
+		return nil, ErrSteamTicketInvalid
+	}
+
+	var authResponse steamAuthenticateResponse
+
+	err := steamGet("/ISteamUserAuth/AuthenticateUserTicket/v1/", url.Values{
+		"key":    {config.C.Steam.APIKey},
+		"appid":  {config.C.Steam.AppID},
+		"ticket": {ticket},
+	}, &authResponse)
+
+	if err != nil {
+		return nil, err
+	}
+
+	params := authResponse.Response.Params
+
+	if authResponse.Response.Error != nil || params == nil || params.Result != "OK" || params.SteamID == "" {
+		return nil, ErrSteamTicketInvalid
+	}
+
+	if params.PublisherBanned {
+		return nil, ErrSteamPublisherBanned
+	}
+
+	player := SteamPlayer{
+		SteamID: params.SteamID,
+	}
+
+	var summaries steamPlayerSummariesResponse
+
+	err = steamGet("/ISteamUser/GetPlayerSummaries/v2/", url.Values{
+		"key":      {config.C.Steam.APIKey},
+		"steamids": {params.SteamID},
+	}, &summaries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, summary := range summaries.Response.Players {
+		if summary.SteamID == params.SteamID {
+			player.PersonaName = summary.PersonaName
+		}
+	}
+
+	return &player, nil
+}
+
+type steamVerifier struct{}
+
+func (v *steamVerifier) Verify(params LoginParams) (*PlatformIdentity, error) {
+	player, err := VerifySteamTicket(params.Ticket)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlatformIdentity{
+		PlatformType:   model.PlatformSteam,
+		PlatformUserID: player.SteamID,
+		PlatformName:   player.PersonaName,
+	}, nil
+}
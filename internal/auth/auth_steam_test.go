@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+)
+
+// steamStub serves the two Steam Web API calls with the given AuthenticateUserTicket response
+func steamStub(t *testing.T, status int, authResponse string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("missing api key in %s", r.URL)
+		}
+
+		switch r.URL.Path {
+		case "/ISteamUserAuth/AuthenticateUserTicket/v1/":
+			if r.URL.Query().Get("appid") != "480" {
+				t.Errorf("missing app id in %s", r.URL)
+			}
+
+			w.WriteHeader(status)
+			w.Write([]byte(authResponse))
+		case "/ISteamUser/GetPlayerSummaries/v2/":
+			w.Write([]byte(`{"response":{"players":[{"steamid":"76561197960287930","personaname":"Spooky"}]}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	previous := config.C.Steam
+	t.Cleanup(func() { config.C.Steam = previous })
+
+	config.C.Steam.APIURL = server.URL
+	config.C.Steam.APIKey = "test-key"
+	config.C.Steam.AppID = "480"
+}
+
+func TestVerifySteamTicket(t *testing.T) {
+	steamStub(t, http.StatusOK, `{"response":{"params":{"result":"OK","steamid":"76561197960287930","ownersteamid":"76561197960287930"}}}`)
+
+	player, err := VerifySteamTicket("ticket")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player.SteamID != "76561197960287930" || player.PersonaName != "Spooky" {
+		t.Errorf("got %+v", player)
+	}
+}
+
+func TestVerifySteamTicketErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     error
+	}{
+		{"invalid ticket", http.StatusOK, `{"response":{"error":{"errorcode":101,"errordesc":"Invalid ticket"}}}`, ErrSteamTicketInvalid},
+		{"result not ok", http.StatusOK, `{"response":{"params":{"result":"Invalid","steamid":""}}}`, ErrSteamTicketInvalid},
+		{"publisher banned", http.StatusOK, `{"response":{"params":{"result":"OK","steamid":"76561197960287930","publisherbanned":true}}}`, ErrSteamPublisherBanned},
+		{"upstream error", http.StatusInternalServerError, `oops`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steamStub(t, tt.status, tt.response)
+
+			player, err := VerifySteamTicket("ticket")
+
+			if err == nil {
+				t.Fatalf("got player %+v, want error", player)
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+
+			// upstream failures are no verdict on the ticket
+			if tt.want == nil && errors.Is(err, ErrSteamTicketInvalid) {
+				t.Errorf("upstream error reported as invalid ticket")
+			}
+		})
+	}
+}
+
+func TestVerifySteamTicketEmpty(t *testing.T) {
+	if _, err := VerifySteamTicket(""); !errors.Is(err, ErrSteamTicketInvalid) {
+		t.Errorf("got %v, want %v", err, ErrSteamTicketInvalid)
+	}
+}
@@ -0,0 +1,165 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Keys are the hex encoded sha256 of the data, so a key
+// always refers to the same data and storing it twice is a no-op.
+type Store interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) ([]byte, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+	// Touch marks an existing blob as just written, it returns ErrNotFound for missing blobs
+	Touch(key string) error
+	// List calls fn for every blob with the time it was last written
+	List(fn func(key string, modified time.Time) error) error
+}
+
+var store Store
+
+// Init creates the store configured in config.C.Blobs
+func Init() error {
+	blobs := config.C.Blobs
+
+	switch blobs.Driver {
+	case "filesystem":
+		fileStore, err := NewFileStore(blobs.Path)
+
+		if err != nil {
+			return err
+		}
+
+		store = fileStore
+	case "s3":
+		store = NewS3Store(S3Options{
+			Endpoint:  blobs.S3.Endpoint,
+			Region:    blobs.S3.Region,
+			Bucket:    blobs.S3.Bucket,
+			AccessKey: blobs.S3.AccessKey,
+			SecretKey: blobs.S3.SecretKey,
+			PathStyle: blobs.S3.PathStyle,
+		})
+	default:
+		return fmt.Errorf("unknown blob driver %s", blobs.Driver)
+	}
+
+	return nil
+}
+
+// UseStore replaces the configured store
+func UseStore(s Store) {
+	store = s
+}
+
+// Key returns the content address of the data
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores the data unless a blob with the same content exists and returns its key.
+// An existing blob is touched, so Collect doesn't delete it before the new reference is committed.
+func Put(data []byte, contentType string) (string, error) {
+	key := Key(data)
+
+	exists, err := store.Exists(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if exists {
+		err = store.Touch(key)
+
+		if err == nil {
+			return key, nil
+		}
+
+		// collected in the meantime
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+
+	if err := store.Put(key, data, contentType); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Get returns the data of the blob or ErrNotFound
+func Get(key string) ([]byte, error) {
+	return store.Get(key)
+}
+
+// collectBatch is how many keys are checked for references at once
+const collectBatch = 500
+
+// Collect deletes the blobs older than minAge that aren't referenced anymore and returns how many it deleted.
+// referenced returns which of the keys are still in use. Blobs are written before the rows referring to
+// them are committed, so minAge has to cover the longest such transaction.
+func Collect(minAge time.Duration, referenced func(keys []string) (map[string]bool, error)) (int, error) {
+	before := time.Now().Add(-minAge)
+	keys := []string{}
+	deleted := 0
+
+	sweep := func() error {
+		inUse, err := referenced(keys)
+
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if inUse[key] {
+				continue
+			}
+
+			if err := store.Delete(key); err != nil {
+				return err
+			}
+
+			deleted++
+		}
+
+		keys = keys[:0]
+
+		return nil
+	}
+
+	err := store.List(func(key string, modified time.Time) error {
+		if modified.After(before) {
+			return nil
+		}
+
+		keys = append(keys, key)
+
+		if len(keys) < collectBatch {
+			return nil
+		}
+
+		return sweep()
+	})
+
+	if err != nil {
+		return deleted, err
+	}
+
+	if len(keys) > 0 {
+		err = sweep()
+	}
+
+	return deleted, err
+}
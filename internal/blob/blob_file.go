@@ -0,0 +1,125 @@
+package blob
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore keeps blobs in a local directory, fanned out by the first characters of the key
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{root: root}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	if len(key) < 4 {
+		return filepath.Join(s.root, key)
+	}
+
+	return filepath.Join(s.root, key[:2], key[2:4], key)
+}
+
+// Put writes to a temporary file first, readers never see partially written blobs
+func (s *FileStore) Put(key string, data []byte, contentType string) error {
+	path := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+func (s *FileStore) Exists(key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *FileStore) Touch(key string) error {
+	now := time.Now()
+	err := os.Chtimes(s.path(key), now, now)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *FileStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// List walks the directory, temporary files of unfinished writes are skipped
+func (s *FileStore) List(fn func(key string, modified time.Time) error) error {
+	return filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return fn(entry.Name(), info.ModTime())
+	})
+}
@@ -0,0 +1,126 @@
+package blob
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key([]byte("level"))
+
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Fatalf("got %v, %v before put", exists, err)
+	}
+
+	if _, err := store.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+
+	if err := store.Put(key, []byte("level"), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.root, key[:2], key[2:4], key)); err != nil {
+		t.Errorf("blob not fanned out: %v", err)
+	}
+
+	data, err := store.Get(key)
+
+	if err != nil || string(data) != "level" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Errorf("got %v, %v after delete", exists, err)
+	}
+
+	// deleting is idempotent
+	if err := store.Delete(key); err != nil {
+		t.Errorf("got %v deleting a missing blob", err)
+	}
+}
+
+func TestFileStoreTouch(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Touch("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+
+	key := Key([]byte("level"))
+
+	if err := store.Put(key, []byte("level"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-24 * time.Hour)
+
+	if err := os.Chtimes(store.path(key), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Touch(key); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(store.path(key))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.ModTime().Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("modified time %s not refreshed", info.ModTime())
+	}
+}
+
+func TestFileStoreList(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key([]byte("level"))
+
+	if err := store.Put(key, []byte("level"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unfinished write
+	if err := os.WriteFile(filepath.Join(store.root, key[:2], key[2:4], ".upload-1"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+
+	err = store.List(func(key string, modified time.Time) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || keys[0] != key {
+		t.Errorf("got %v, want [%s]", keys, key)
+	}
+}
@@ -0,0 +1,316 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket in the path instead of the host name, which MinIO and most
+	// other S3 compatible servers need
+	PathStyle bool
+}
+
+// S3Store keeps blobs in an S3 compatible bucket. Requests are signed with AWS signature version 4.
+type S3Store struct {
+	options S3Options
+	client  *http.Client
+}
+
+func NewS3Store(options S3Options) *S3Store {
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+
+	return &S3Store{
+		options: options,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	endpoint, err := url.Parse(s.options.Endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.options.PathStyle {
+		endpoint.Path = "/" + s.options.Bucket + "/" + key
+	} else {
+		endpoint.Host = s.options.Bucket + "." + endpoint.Host
+		endpoint.Path = "/" + key
+	}
+
+	return endpoint, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// sign adds the authorization header of signature version 4, signing the host, the content type
+// and all x-amz headers. Keys are hex strings, so the path never needs escaping beyond what url.URL already does.
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{}
+
+	for name := range req.Header {
+		name = strings.ToLower(name)
+
+		if name == "host" || name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+
+	for _, name := range signedHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.options.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.options.AccessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// do sends a signed request for the object, an empty key addresses the bucket
+func (s *S3Store) do(method string, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// signature version 4 wants the query sorted and spaces escaped as %20
+	objectURL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequest(method, objectURL.String(), bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.sign(req, sha256Hex(body), time.Now())
+
+	return s.client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return fmt.Errorf("s3 responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+func (s *S3Store) Put(key string, data []byte, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, nil, data, http.Header{"Content-Type": {contentType}})
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+func (s *S3Store) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3Store) Exists(key string) (bool, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("s3 responded with %d", resp.StatusCode)
+	}
+}
+
+// Touch copies the object onto itself, which S3 only allows when replacing the metadata
+func (s *S3Store) Touch(key string) error {
+	resp, err := s.do(http.MethodPut, key, nil, nil, http.Header{
+		"X-Amz-Copy-Source":        {"/" + s.options.Bucket + "/" + key},
+		"X-Amz-Metadata-Directive": {"REPLACE"},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through the bucket with ListObjectsV2
+func (s *S3Store) List(fn func(key string, modified time.Time) error) error {
+	query := url.Values{"list-type": {"2"}}
+
+	for {
+		result, err := s.listPage(query)
+
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			if err := fn(object.Key, object.LastModified); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *S3Store) listPage(query url.Values) (*s3ListResult, error) {
+	resp, err := s.do(http.MethodGet, "", query, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+
+	var result s3ListResult
+
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,243 @@
+package blob
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3Stub is an in-memory bucket speaking enough of the S3 api for the store, listing one object per page
+type s3Stub struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	copies  int
+	fail    bool
+}
+
+func (b *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		b.t.Errorf("unsigned request %s %s", r.Method, r.URL)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		b.t.Errorf("payload hash doesn't match the body of %s %s", r.Method, r.URL)
+	}
+
+	if b.fail {
+		http.Error(w, "<Error><Code>InternalError</Code></Error>", http.StatusInternalServerError)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.URL.Path == "/bucket/" {
+		b.list(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	data, ok := b.objects[key]
+
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			if !strings.Contains(r.Header.Get("Authorization"), "x-amz-copy-source") {
+				b.t.Errorf("copy source not signed")
+			}
+
+			if _, ok := b.objects[strings.TrimPrefix(source, "/bucket/")]; !ok {
+				http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+				return
+			}
+
+			b.copies++
+			return
+		}
+
+		b.objects[key] = body
+	case http.MethodGet, http.MethodHead:
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (b *s3Stub) list(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("list-type") != "2" {
+		b.t.Errorf("unexpected bucket request %s", r.URL)
+	}
+
+	keys := make([]string, 0, len(b.objects))
+
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	type object struct {
+		Key          string
+		LastModified time.Time
+	}
+
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []object
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+
+	// the token is the key to continue after
+	after := r.URL.Query().Get("continuation-token")
+
+	for i, key := range keys {
+		if key <= after {
+			continue
+		}
+
+		result.Contents = append(result.Contents, object{Key: key, LastModified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+		result.IsTruncated = i < len(keys)-1
+		result.NextContinuationToken = key
+
+		break
+	}
+
+	xml.NewEncoder(w).Encode(result)
+}
+
+func newS3Stub(t *testing.T) (*s3Stub, *S3Store) {
+	t.Helper()
+
+	bucket := &s3Stub{t: t, objects: map[string][]byte{}}
+	server := httptest.NewServer(bucket)
+
+	t.Cleanup(server.Close)
+
+	return bucket, NewS3Store(S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+}
+
+func TestS3Store(t *testing.T) {
+	_, store := newS3Stub(t)
+
+	key := Key([]byte("level"))
+
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Fatalf("got %v, %v before put", exists, err)
+	}
+
+	if _, err := store.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+
+	if err := store.Put(key, []byte("level"), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := store.Exists(key); err != nil || !exists {
+		t.Fatalf("got %v, %v after put", exists, err)
+	}
+
+	data, err := store.Get(key)
+
+	if err != nil || string(data) != "level" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v after delete, want %v", err, ErrNotFound)
+	}
+}
+
+func TestS3StoreTouch(t *testing.T) {
+	bucket, store := newS3Stub(t)
+
+	if err := store.Touch("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+
+	bucket.objects["a"] = []byte("a")
+
+	if err := store.Touch("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if bucket.copies != 1 {
+		t.Errorf("got %d copies, want 1", bucket.copies)
+	}
+}
+
+func TestS3StoreList(t *testing.T) {
+	bucket, store := newS3Stub(t)
+
+	bucket.objects = map[string][]byte{"a": nil, "b": nil, "c": nil}
+
+	var keys []string
+
+	err := store.List(func(key string, modified time.Time) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(keys, ",") != "a,b,c" {
+		t.Errorf("got %v across pages", keys)
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	bucket, store := newS3Stub(t)
+
+	bucket.fail = true
+
+	if err := store.Put("a", []byte("a"), ""); err == nil {
+		t.Error("put succeeded")
+	}
+
+	if _, err := store.Get("a"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v from get, want an upstream error", err)
+	}
+
+	if _, err := store.Exists("a"); err == nil {
+		t.Error("exists succeeded")
+	}
+
+	if err := store.Delete("a"); err == nil {
+		t.Error("delete succeeded")
+	}
+
+	if err := store.List(func(string, time.Time) error { return nil }); err == nil {
+		t.Error("list succeeded")
+	}
+}
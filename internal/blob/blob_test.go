@@ -0,0 +1,106 @@
+package blob
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := store
+	t.Cleanup(func() { store = previous })
+
+	UseStore(fileStore)
+
+	put := func(data string, age time.Duration) string {
+		key, err := Put([]byte(data), "")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modified := time.Now().Add(-age)
+
+		if err := os.Chtimes(fileStore.path(key), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+
+		return key
+	}
+
+	used := put("used", 2*time.Hour)
+	unused := put("unused", 2*time.Hour)
+	recent := put("recent", time.Minute)
+
+	deleted, err := Collect(time.Hour, func(keys []string) (map[string]bool, error) {
+		for _, key := range keys {
+			if key == recent {
+				t.Errorf("recent blob %s checked", key)
+			}
+		}
+
+		return map[string]bool{used: true}, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("deleted %d blobs, want 1", deleted)
+	}
+
+	for key, want := range map[string]bool{used: true, unused: false, recent: true} {
+		if exists, _ := fileStore.Exists(key); exists != want {
+			t.Errorf("blob %s exists %v, want %v", key, exists, want)
+		}
+	}
+}
+
+func TestCollectKeepsReusedBlobs(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := store
+	t.Cleanup(func() { store = previous })
+
+	UseStore(fileStore)
+
+	key, err := Put([]byte("replay"), "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	if err := os.Chtimes(fileStore.path(key), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored again for a row that isn't committed yet
+	if _, err := Put([]byte("replay"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := Collect(time.Hour, func(keys []string) (map[string]bool, error) {
+		return map[string]bool{}, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 0 {
+		t.Errorf("deleted %d blobs, want the reused blob kept", deleted)
+	}
+}
@@ -19,11 +19,157 @@ type Database struct {
 	DatabaseName string `mapstructure:"databaseName"`
 }
 
+type Steam struct {
+	APIURL string `mapstructure:"apiUrl"`
+	APIKey string `mapstructure:"apiKey"`
+	AppID  string `mapstructure:"appId"`
+}
+
+type Nintendo struct {
+	JWKSURL  string `mapstructure:"jwksUrl"`
+	JWKSFile string `mapstructure:"jwksFile"`
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+}
+
+// Jobs configures the intervals of the background jobs in minutes, 0 disables a job
+type Jobs struct {
+	TokenCleanupInterval          int `mapstructure:"tokenCleanupInterval"`
+	ValidationLockCleanupInterval int `mapstructure:"validationLockCleanupInterval"`
+	AccountDeletionInterval       int `mapstructure:"accountDeletionInterval"`
+	VoteReconcileInterval         int `mapstructure:"voteReconcileInterval"`
+	RankingRefreshInterval        int `mapstructure:"rankingRefreshInterval"`
+	PlayReconcileInterval         int `mapstructure:"playReconcileInterval"`
+	BlobCollectionInterval        int `mapstructure:"blobCollectionInterval"`
+}
+
+// Privacy configures how account deletion requests are handled, the grace period is given in days
+type Privacy struct {
+	DeletionPolicy    string `mapstructure:"deletionPolicy"`
+	DeletionGraceDays int    `mapstructure:"deletionGraceDays"`
+}
+
+// RateLimit configures the abuse protection of the login and the play counters, 0 disables a limit
+type RateLimit struct {
+	LoginPerIPPerMinute          int `mapstructure:"loginPerIpPerMinute"`
+	LoginPerPlatformIDPerMinute  int `mapstructure:"loginPerPlatformIdPerMinute"`
+	AnonymousAccountsPerIPPerDay int `mapstructure:"anonymousAccountsPerIpPerDay"`
+	PlayStartsPerLevelPerHour    int `mapstructure:"playStartsPerLevelPerHour"`
+}
+
+// Proxy configures which reverse proxies are trusted to report the client ip in X-Forwarded-For,
+// requests from anywhere else keep their remote address. The trusted platform names a header set
+// by a CDN instead, e.g. CF-Connecting-IP.
+type Proxy struct {
+	TrustedProxies  []string `mapstructure:"trustedProxies"`
+	TrustedPlatform string   `mapstructure:"trustedPlatform"`
+}
+
+// Pagination configures list pages. Cursors are signed with the secret, all replicas need the same one.
+type Pagination struct {
+	CursorSecret    string `mapstructure:"cursorSecret"`
+	DefaultPageSize int    `mapstructure:"defaultPageSize"`
+	MaxPageSize     int    `mapstructure:"maxPageSize"`
+}
+
+// Ranking configures the level rankings. Hot scores sink with the level age in hours raised to
+// the gravity, trending scores only count votes, plays and clears of the window, halved every half life.
+// Players and clears add to both scores next to the votes, the trending play weight scales them
+// against the votes of the trending score.
+type Ranking struct {
+	LikeWeight            float64 `mapstructure:"likeWeight"`
+	DislikeWeight         float64 `mapstructure:"dislikeWeight"`
+	PlayerWeight          float64 `mapstructure:"playerWeight"`
+	ClearWeight           float64 `mapstructure:"clearWeight"`
+	HotGravity            float64 `mapstructure:"hotGravity"`
+	TrendingWindowDays    int     `mapstructure:"trendingWindowDays"`
+	TrendingHalfLifeHours float64 `mapstructure:"trendingHalfLifeHours"`
+	WilsonZ               float64 `mapstructure:"wilsonZ"`
+	TrendingPlayWeight    float64 `mapstructure:"trendingPlayWeight"`
+}
+
+// LevelFormat limits the content of uploaded levels, 0 disables a limit. Submitted levels
+// above the max complexity are rejected as too complex without waiting for an agent.
+// Content that doesn't follow the level format is only rejected with enforce set, until the
+// format is confirmed against levels exported by the game just the content size is enforced.
+type LevelFormat struct {
+	Enforce         bool `mapstructure:"enforce"`
+	MaxContentBytes int  `mapstructure:"maxContentBytes"`
+	MaxWidth        int  `mapstructure:"maxWidth"`
+	MaxHeight       int  `mapstructure:"maxHeight"`
+	MaxTiles        int  `mapstructure:"maxTiles"`
+	MaxObjects      int  `mapstructure:"maxObjects"`
+	MaxPathPoints   int  `mapstructure:"maxPathPoints"`
+	MaxComplexity   int  `mapstructure:"maxComplexity"`
+}
+
+// Thumbnails limits uploaded thumbnail images. Thumbnail urls start with the base url,
+// which can point to a CDN in front of the server. The max age is given in seconds.
+type Thumbnails struct {
+	MaxBytes    int    `mapstructure:"maxBytes"`
+	MinWidth    int    `mapstructure:"minWidth"`
+	MinHeight   int    `mapstructure:"minHeight"`
+	MaxWidth    int    `mapstructure:"maxWidth"`
+	MaxHeight   int    `mapstructure:"maxHeight"`
+	CacheMaxAge int    `mapstructure:"cacheMaxAge"`
+	BaseURL     string `mapstructure:"baseUrl"`
+}
+
+// Leaderboard limits submitted scores, 0 disables a limit. Scores above the max score are rejected,
+// scores above the author score times the flag factor are kept but left out of the leaderboards.
+type Leaderboard struct {
+	MaxReplayBytes int     `mapstructure:"maxReplayBytes"`
+	MaxScore       int     `mapstructure:"maxScore"`
+	FlagFactor     float64 `mapstructure:"flagFactor"`
+}
+
+type S3 struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"accessKey"`
+	SecretKey string `mapstructure:"secretKey"`
+	PathStyle bool   `mapstructure:"pathStyle"`
+}
+
+// Blobs configures where level content, replays and thumbnails are stored.
+// The driver is filesystem, storing below path, or s3.
+type Blobs struct {
+	Driver string `mapstructure:"driver"`
+	Path   string `mapstructure:"path"`
+	S3     S3     `mapstructure:"s3"`
+}
+
+// JWTSigningKey is a key for access tokens. HS* algorithms use the secret,
+// RS256 and EdDSA read PEM encoded keys, verification only keys need just the public key.
+type JWTSigningKey struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"`
+	Secret         string `mapstructure:"secret"`
+	PrivateKeyFile string `mapstructure:"privateKeyFile"`
+	PublicKeyFile  string `mapstructure:"publicKeyFile"`
+}
+
 type Config struct {
-	Database      Database    `mapstructure:"database"`
-	JWTKey        string      `mapstructure:"jwt_key"`
-	TokenLifeSpan int         `mapstructure:"TokenLifeSpan"`
-	Environment   Environment `mapstructure:"Environment"`
+	Database             Database        `mapstructure:"database"`
+	JWTKey               string          `mapstructure:"JWTKey"`
+	JWTKeys              []JWTSigningKey `mapstructure:"jwtKeys"`
+	JWTActiveKeyID       string          `mapstructure:"jwtActiveKeyId"`
+	TokenLifeSpan        int             `mapstructure:"TokenLifeSpan"`
+	RefreshTokenLifeSpan int             `mapstructure:"RefreshTokenLifeSpan"`
+	Environment          Environment     `mapstructure:"Environment"`
+	Steam                Steam           `mapstructure:"steam"`
+	Nintendo             Nintendo        `mapstructure:"nintendo"`
+	Jobs                 Jobs            `mapstructure:"jobs"`
+	Privacy              Privacy         `mapstructure:"privacy"`
+	RateLimit            RateLimit       `mapstructure:"rateLimit"`
+	Proxy                Proxy           `mapstructure:"proxy"`
+	Pagination           Pagination      `mapstructure:"pagination"`
+	Ranking              Ranking         `mapstructure:"ranking"`
+	LevelFormat          LevelFormat     `mapstructure:"levelFormat"`
+	Thumbnails           Thumbnails      `mapstructure:"thumbnails"`
+	Blobs                Blobs           `mapstructure:"blobs"`
+	Leaderboard          Leaderboard     `mapstructure:"leaderboard"`
 }
 
 var C Config
@@ -33,11 +179,56 @@ func Init() error {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 
+	viper.SetDefault("TokenLifeSpan", 15)
+	viper.SetDefault("RefreshTokenLifeSpan", 24*30)
+	viper.SetDefault("steam.apiUrl", "https://api.steampowered.com")
+	viper.SetDefault("jobs.tokenCleanupInterval", 60)
+	viper.SetDefault("jobs.validationLockCleanupInterval", 5)
+	viper.SetDefault("jobs.accountDeletionInterval", 60)
+	viper.SetDefault("jobs.voteReconcileInterval", 60)
+	viper.SetDefault("jobs.rankingRefreshInterval", 10)
+	viper.SetDefault("jobs.playReconcileInterval", 60)
+	viper.SetDefault("jobs.blobCollectionInterval", 1440)
+	viper.SetDefault("privacy.deletionPolicy", "anonymise")
+	viper.SetDefault("privacy.deletionGraceDays", 14)
+	viper.SetDefault("rateLimit.loginPerIpPerMinute", 20)
+	viper.SetDefault("rateLimit.loginPerPlatformIdPerMinute", 5)
+	viper.SetDefault("rateLimit.anonymousAccountsPerIpPerDay", 10)
+	viper.SetDefault("rateLimit.playStartsPerLevelPerHour", 30)
+	viper.SetDefault("pagination.defaultPageSize", 20)
+	viper.SetDefault("pagination.maxPageSize", 100)
+	viper.SetDefault("ranking.likeWeight", 1)
+	viper.SetDefault("ranking.dislikeWeight", 1)
+	viper.SetDefault("ranking.playerWeight", 0.1)
+	viper.SetDefault("ranking.clearWeight", 0.2)
+	viper.SetDefault("ranking.hotGravity", 1.8)
+	viper.SetDefault("ranking.trendingWindowDays", 7)
+	viper.SetDefault("ranking.trendingHalfLifeHours", 48)
+	viper.SetDefault("ranking.wilsonZ", 1.96)
+	viper.SetDefault("ranking.trendingPlayWeight", 0.1)
+	viper.SetDefault("levelFormat.maxContentBytes", 1<<20)
+	viper.SetDefault("levelFormat.maxWidth", 1024)
+	viper.SetDefault("levelFormat.maxHeight", 256)
+	viper.SetDefault("levelFormat.maxTiles", 100000)
+	viper.SetDefault("levelFormat.maxObjects", 2000)
+	viper.SetDefault("levelFormat.maxPathPoints", 64)
+	viper.SetDefault("levelFormat.maxComplexity", 50000)
+	viper.SetDefault("thumbnails.maxBytes", 4<<20)
+	viper.SetDefault("thumbnails.minWidth", 160)
+	viper.SetDefault("thumbnails.minHeight", 90)
+	viper.SetDefault("thumbnails.maxWidth", 4096)
+	viper.SetDefault("thumbnails.maxHeight", 4096)
+	viper.SetDefault("thumbnails.cacheMaxAge", 60*60*24*365)
+	viper.SetDefault("blobs.driver", "filesystem")
+	viper.SetDefault("blobs.path", "blobs")
+	viper.SetDefault("leaderboard.maxReplayBytes", 1<<20)
+	viper.SetDefault("leaderboard.flagFactor", 3)
+
 	err := viper.ReadInConfig()
 
 	if err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			fmt.Errorf("config file not found")
+			fmt.Println("config file not found")
 		} else {
 			return err
 		}
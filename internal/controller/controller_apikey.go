@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/audit"
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type apiKeyParams struct {
+	Name          string           `json:"name"`
+	UserID        uuid.UUID        `json:"userId"`
+	Scopes        []model.APIScope `json:"scopes"`
+	ExpiresInDays int              `json:"expiresInDays"`
+}
+
+type apiKeyGetParams struct {
+	UserID string `form:"user_id"`
+}
+
+func isValidAPIScope(scope model.APIScope) bool {
+	switch scope {
+	case model.ScopeLevelsReadUnvalidated, model.ScopeLevelsValidate:
+		return true
+	default:
+		return false
+	}
+}
+
+func apiKeysAdd(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		var keyParams apiKeyParams
+
+		if err := context.BindJSON(&keyParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if keyParams.Name == "" || len(keyParams.Scopes) == 0 {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "name and scopes are required"})
+			return
+		}
+
+		for _, scope := range keyParams.Scopes {
+			if !isValidAPIScope(scope) {
+				context.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope " + scope})
+				return
+			}
+		}
+
+		var agent model.User
+
+		tx := db.Where("id = ?", keyParams.UserID).Limit(1).Find(&agent)
+
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if agent.Role != model.UserRoleAgent {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "api keys can only be issued to validation agents"})
+			return
+		}
+
+		var expiresAt *time.Time
+
+		if keyParams.ExpiresInDays > 0 {
+			expiry := time.Now().AddDate(0, 0, keyParams.ExpiresInDays)
+			expiresAt = &expiry
+		}
+
+		var apiKey *model.APIKey
+		var key string
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+
+			apiKey, key, err = auth.CreateAPIKey(tx, agent.ID, user.ID, keyParams.Name, keyParams.Scopes, expiresAt)
+
+			if err != nil {
+				return err
+			}
+
+			return audit.Record(tx, &user.ID, model.AuditSourceAPI, model.AuditAPIKeyCreated, agent.ID, map[string]interface{}{
+				"apiKeyId": apiKey.ID,
+				"name":     apiKey.Name,
+				"scopes":   apiKey.Scopes,
+			})
+		})
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"apiKey": apiKey,
+			"key":    key,
+		})
+	}
+}
+
+func apiKeysGetAll(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		var getParams apiKeyGetParams
+
+		if err := context.BindQuery(&getParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		apiKeys := []model.APIKey{}
+
+		tx := db.Model(&model.APIKey{})
+
+		if getParams.UserID != "" {
+			tx = tx.Where("user_id = ?", getParams.UserID)
+		}
+
+		if err := tx.Order("created_at desc").Find(&apiKeys).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"apiKeys": apiKeys,
+		})
+	}
+}
+
+func apiKeyRevoke(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		apiKeyID, err := uuid.Parse(context.Param("apiKeyId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var apiKey model.APIKey
+
+		tx := db.Where("id = ? AND revoked_at is null", apiKeyID).Limit(1).Find(&apiKey)
+
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+
+			return audit.Record(tx, &user.ID, model.AuditSourceAPI, model.AuditAPIKeyRevoked, apiKey.UserID, map[string]interface{}{
+				"apiKeyId": apiKey.ID,
+				"name":     apiKey.Name,
+			})
+		})
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func UseAPIKey(router gin.IRouter, db *gorm.DB) {
+	apiKeyRouter := router.Group("/apikeys")
+
+	apiKeyRouter.GET("", apiKeysGetAll(db))
+	apiKeyRouter.POST("", apiKeysAdd(db))
+	apiKeyRouter.DELETE("/:apiKeyId", apiKeyRevoke(db))
+}
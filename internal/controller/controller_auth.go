@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
@@ -40,10 +41,100 @@ func authLogout(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+type refreshParams struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func authRefresh(db *gorm.DB, mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var params refreshParams
+
+		if err := context.BindJSON(&params); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userToken, refreshToken, err := auth.RotateRefreshToken(db, mw, params.RefreshToken)
+
+		if err != nil {
+			if errors.Is(err, auth.ErrRefreshTokenInvalid) || errors.Is(err, auth.ErrRefreshTokenReused) {
+				context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"token":        userToken.Token,
+			"expire":       userToken.ValidUntil,
+			"refreshToken": refreshToken,
+		})
+	}
+}
+
+// apiKeyRouteScopes lists the only routes api keys are accepted on and the scope they need
+var apiKeyRouteScopes = map[string]model.APIScope{
+	"GET /levels":                   model.ScopeLevelsReadUnvalidated,
+	"PUT /levels/:levelId/lock":     model.ScopeLevelsValidate,
+	"PUT /levels/:levelId/validate": model.ScopeLevelsValidate,
+}
+
+// authMiddlewareFunc accepts api keys of validation agents alongside jwts
+func authMiddlewareFunc(db *gorm.DB, mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	jwtMiddleware := mw.MiddlewareFunc()
+
+	return func(context *gin.Context) {
+		if !auth.HasAPIKey(context) {
+			jwtMiddleware(context)
+			return
+		}
+
+		apiKey, _ := auth.CheckAPIKey(context, db)
+
+		if apiKey == nil {
+			return
+		}
+
+		scope, ok := apiKeyRouteScopes[context.Request.Method+" "+context.FullPath()]
+
+		if !ok || !apiKey.HasScope(scope) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is missing the required scope"})
+			return
+		}
+	}
+}
+
 func authCheckTokenActivityMiddlewareFunc(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
-		_, _, _ = auth.CheckTokenActivity(context, db)
+		if auth.GetAPIKey(context) == nil {
+			if active, _, _ := auth.CheckTokenActivity(context, db); !active {
+				return
+			}
+		}
+
+		if user := auth.GetJWTUser(context); user != nil {
+			abortIfBanned(context, db, user)
+		}
+	}
+}
+
+// abortIfBanned rejects the request if the user has a full ban or a ban for one of the scopes
+func abortIfBanned(context *gin.Context, db *gorm.DB, user *model.User, scopes ...model.BanScope) bool {
+	ban, err := auth.FindActiveBan(db, user.ID, scopes...)
+
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
 	}
+
+	if ban != nil {
+		context.AbortWithStatusJSON(http.StatusForbidden, auth.BanResponse(ban))
+		return true
+	}
+
+	return false
 }
 
 func UseAuth(router gin.IRouter, db *gorm.DB) error {
@@ -54,14 +145,16 @@ func UseAuth(router gin.IRouter, db *gorm.DB) error {
 	}
 
 	//router.POST("/auth/signup", authSignUp(db))
-	router.POST("/auth/login", mw.LoginHandler)
+	router.GET("/.well-known/jwks.json", auth.JWKSHandler)
+	router.POST("/auth/login", auth.LoginHandler(mw))
+	// the access token may already be expired when refreshing, so this has to stay outside of the jwt middleware
+	router.POST("/auth/refresh_token", authRefresh(db, mw))
 
-	router.Use(mw.MiddlewareFunc())
+	router.Use(authMiddlewareFunc(db, mw))
 	router.Use(authCheckTokenActivityMiddlewareFunc(db))
 
 	authRouter := router.Group("/auth")
 
-	authRouter.POST("refresh_token", mw.RefreshHandler)
 	authRouter.POST("logout", authLogout(db))
 
 	return nil
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/audit"
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type banParams struct {
+	Reason        string         `json:"reason"`
+	Scope         model.BanScope `json:"scope"`
+	DurationHours int            `json:"durationHours"`
+}
+
+func isValidBanScope(scope model.BanScope) bool {
+	switch scope {
+	case model.BanScopeFull, model.BanScopeUpload, model.BanScopeVote, model.BanScopeReport:
+		return true
+	default:
+		return false
+	}
+}
+
+func bansAdd(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		userID, err := uuid.Parse(context.Param("userId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var addParams banParams
+
+		if err := context.BindJSON(&addParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if addParams.Scope == "" {
+			addParams.Scope = model.BanScopeFull
+		}
+
+		if !isValidBanScope(addParams.Scope) {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "unknown ban scope"})
+			return
+		}
+
+		if addParams.Reason == "" {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "missing reason"})
+			return
+		}
+
+		var bannedUser model.User
+
+		tx := db.Where("id = ?", userID).Limit(1).Find(&bannedUser)
+
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if auth.IsModerator(&bannedUser) {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "moderators can't be banned"})
+			return
+		}
+
+		ban := model.Ban{
+			UserID:     bannedUser.ID,
+			IssuedByID: user.ID,
+			Reason:     addParams.Reason,
+			Scope:      addParams.Scope,
+		}
+
+		if addParams.DurationHours > 0 {
+			expiresAt := time.Now().Add(time.Hour * time.Duration(addParams.DurationHours))
+			ban.ExpiresAt = &expiresAt
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&ban).Error; err != nil {
+				return err
+			}
+
+			return audit.Record(tx, &user.ID, model.AuditSourceAPI, model.AuditUserBanned, bannedUser.ID, map[string]interface{}{
+				"banId":     ban.ID,
+				"scope":     ban.Scope,
+				"reason":    ban.Reason,
+				"expiresAt": ban.ExpiresAt,
+			})
+		})
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"banId": ban.ID})
+	}
+}
+
+func bansGetByUser(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		userID, err := uuid.Parse(context.Param("userId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		bans := []model.Ban{}
+
+		if err := db.Where("user_id = ?", userID).Order("created_at desc").Find(&bans).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"bans": bans})
+	}
+}
+
+func banLift(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		banID, err := uuid.Parse(context.Param("banId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ban model.Ban
+
+		tx := db.Where("id = ? AND lifted_at is null", banID).Limit(1).Find(&ban)
+
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "ban not found"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&ban).Updates(map[string]interface{}{
+				"lifted_at":    time.Now(),
+				"lifted_by_id": user.ID,
+			}).Error; err != nil {
+				return err
+			}
+
+			return audit.Record(tx, &user.ID, model.AuditSourceAPI, model.AuditBanLifted, ban.UserID, map[string]interface{}{
+				"banId": ban.ID,
+			})
+		})
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func UseBan(router gin.IRouter, db *gorm.DB) {
+	router.GET("/users/:userId/bans", bansGetByUser(db))
+	router.POST("/users/:userId/bans", bansAdd(db))
+	router.DELETE("/bans/:banId", banLift(db))
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/account"
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func identitiesGetOwn(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		identities := []model.UserIdentity{}
+
+		if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&identities).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"identities": identities})
+	}
+}
+
+// identityLink verifies the login params of another platform and links that identity to the account.
+// If the caller's anonymous account gets merged into an existing platform account, the caller's
+// sessions are gone and the client has to log in again with the linked platform.
+func identityLink(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		var linkParams auth.LoginParams
+
+		if err := context.BindJSON(&linkParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		identity, err := auth.VerifyPlatformIdentity(linkParams)
+
+		if err != nil {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		survivor, err := account.LinkIdentity(db, user, identity.UserIdentity())
+
+		if err != nil {
+			if errors.Is(err, account.ErrMergeConflict) {
+				context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"userId":          survivor.ID,
+			"reloginRequired": survivor.ID != user.ID,
+		})
+	}
+}
+
+func UseIdentity(router gin.IRouter, db *gorm.DB) {
+	router.GET("/me/identities", identitiesGetOwn(db))
+	router.POST("/me/identities", identityLink(db))
+}
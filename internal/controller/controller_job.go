@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func jobsGetStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
+		}
+
+		jobRuns := []model.JobRun{}
+
+		if err := db.Order("name").Find(&jobRuns).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"jobs": jobRuns,
+		})
+	}
+}
+
+func UseJob(router gin.IRouter, db *gorm.DB) {
+	router.GET("/jobs", jobsGetStatus(db))
+}
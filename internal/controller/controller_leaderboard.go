@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/internal/leaderboard"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelquery"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type scoreSubmitParams struct {
+	Score  int    `json:"score"`
+	TimeMs uint   `json:"timeMs"`
+	Replay string `json:"replay"`
+}
+
+type leaderboardParams struct {
+	Scope    string `form:"scope"`
+	Limit    int    `form:"limit"`
+	Platform string `form:"platform"`
+	Friends  string `form:"friends"`
+}
+
+// leaderboardError answers with the status matching an error of the leaderboard package
+func leaderboardError(context *gin.Context, err error) {
+	var rejected *leaderboard.RejectedError
+
+	switch {
+	case errors.As(err, &rejected):
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "reason": rejected.Reason})
+	case errors.Is(err, leaderboard.ErrLevelNotFound), errors.Is(err, leaderboard.ErrScoreNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, leaderboard.ErrReplayTooLarge):
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, leaderboard.ErrUnknownScope):
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// levelScoreSubmit takes a score with its replay, only scores beating the best score of the user are kept
+func levelScoreSubmit(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if abortIfBanned(context, db, user, model.BanScopeUpload) {
+			return
+		}
+
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var submitParams scoreSubmitParams
+
+		if err := context.BindJSON(&submitParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		best, improved, err := leaderboard.Submit(db, user.ID, levelID, submitParams.Score, submitParams.TimeMs, submitParams.Replay)
+
+		if err != nil {
+			leaderboardError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"improved": improved,
+			"best":     best,
+		})
+	}
+}
+
+// levelLeaderboard lists the top scores, the scores of the friends of the user or the scores around
+// the user. Friends are comma separated platform user ids on the platform of the user unless given.
+func levelLeaderboard(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var params leaderboardParams
+
+		if err := context.BindQuery(&params); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query := leaderboard.Query{
+			LevelID:  levelID,
+			UserID:   user.ID,
+			Scope:    params.Scope,
+			Size:     levelquery.PageSize(params.Limit),
+			Platform: params.Platform,
+			Friends:  []string{},
+		}
+
+		if query.Scope == "" {
+			query.Scope = leaderboard.ScopeGlobal
+		}
+
+		if query.Platform == "" {
+			query.Platform = user.PlatformType
+		}
+
+		for _, friend := range strings.Split(params.Friends, ",") {
+			if friend = strings.TrimSpace(friend); friend != "" {
+				query.Friends = append(query.Friends, friend)
+			}
+		}
+
+		entries, mine, err := leaderboard.Find(db, query)
+
+		if err != nil {
+			leaderboardError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"scope":   query.Scope,
+			"entries": entries,
+			"me":      mine,
+		})
+	}
+}
+
+// scoreReplayGet serves the replay of a ranked score, e.g. to race against it
+func scoreReplayGet(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		scoreID, err := uuid.Parse(context.Param("scoreId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		score, err := leaderboard.FindRanked(db, scoreID)
+
+		if err != nil {
+			leaderboardError(context, err)
+			return
+		}
+
+		if notModified(context, score.ReplayKey) {
+			return
+		}
+
+		replay, err := levelcontent.Read(score.ReplayKey, "")
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.Data(http.StatusOK, "application/octet-stream", []byte(replay))
+	}
+}
+
+func UseLeaderboard(router gin.IRouter, db *gorm.DB) {
+	router.POST("/levels/:levelId/scores", levelScoreSubmit(db))
+	router.GET("/levels/:levelId/leaderboard", levelLeaderboard(db))
+	router.GET("/scores/:scoreId/replay", scoreReplayGet(db))
+}
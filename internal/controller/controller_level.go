@@ -1,13 +1,24 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
 	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelquery"
+	"github.com/Lyretto/spooky-bodies-golang/internal/review"
+	"github.com/Lyretto/spooky-bodies-golang/internal/revision"
+	"github.com/Lyretto/spooky-bodies-golang/internal/thumbnail"
+	"github.com/Lyretto/spooky-bodies-golang/internal/voting"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/levelformat"
 	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/sharecode"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -15,15 +26,134 @@ import (
 )
 
 type levelParams struct {
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	Replay  string `json:"replay"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	Replay     string `json:"replay"`
+	Difficulty uint   `json:"difficulty"`
+	Submit     bool   `json:"submit"`
 }
 
 type levelGetParams struct {
-	Offset  int `form:"offset"`
-	Limit   int `form:"limit"`
-	OnlySus int `form:"only_sus"`
+	Limit         int       `form:"limit"`
+	Cursor        string    `form:"cursor"`
+	OnlySus       int       `form:"only_sus"`
+	Sort          string    `form:"sort"`
+	Order         string    `form:"order"`
+	Author        string    `form:"author"`
+	Result        string    `form:"result"`
+	State         string    `form:"state"`
+	PublishedFrom time.Time `form:"published_from"`
+	PublishedTo   time.Time `form:"published_to"`
+	DifficultyMin uint      `form:"difficulty_min"`
+	DifficultyMax uint      `form:"difficulty_max"`
+	Search        string    `form:"q"`
+}
+
+// query validates the filters, sort order and cursor of the query parameters
+func (p *levelGetParams) query() (levelquery.Filter, levelquery.Page, error) {
+	filter := levelquery.Filter{
+		Result:        p.Result,
+		State:         p.State,
+		PublishedFrom: p.PublishedFrom,
+		PublishedTo:   p.PublishedTo,
+		DifficultyMin: p.DifficultyMin,
+		DifficultyMax: p.DifficultyMax,
+		Search:        p.Search,
+	}
+
+	if p.Author != "" {
+		authorID, err := uuid.Parse(p.Author)
+
+		if err != nil {
+			return filter, levelquery.Page{}, err
+		}
+
+		filter.AuthorID = &authorID
+	}
+
+	if p.Order != "" && p.Order != "asc" && p.Order != "desc" {
+		return filter, levelquery.Page{}, errors.New("order must be asc or desc")
+	}
+
+	page, err := levelquery.NewPage(p.Sort, p.Order == "asc", p.Cursor, p.Limit)
+
+	return filter, page, err
+}
+
+// fillLevelDetails adds the vote of the user and the thumbnail urls to the levels
+func fillLevelDetails(db *gorm.DB, user *model.User, levels []model.Level) error {
+	if err := voting.FillMyVotes(db, user.ID, levels); err != nil {
+		return err
+	}
+
+	return thumbnail.FillURLs(db, levels)
+}
+
+// levelListQuery selects levels for lists, which only show summaries. Content and replays
+// can be large and are left out.
+func levelListQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.Level{}).Preload("User").Omit("content", "author_replay", "thumbnail")
+}
+
+// levelPageResponse sends a page of level summaries, nextCursor is null on the last page
+func levelPageResponse(context *gin.Context, levels []model.Level, total int64, next *levelquery.Cursor) {
+	var nextCursor *string
+
+	if next != nil {
+		encoded, err := next.Encode()
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		nextCursor = &encoded
+	}
+
+	summaries := make([]model.LevelSummary, len(levels))
+
+	for i := range levels {
+		summaries[i] = levels[i].Summary()
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"levels":     summaries,
+		"total":      total,
+		"nextCursor": nextCursor,
+	})
+}
+
+// parseLevelContent checks uploaded level content and answers with the format errors if it's invalid.
+// It returns the complexity of the level, which is 0 for content accepted without following the format.
+func parseLevelContent(context *gin.Context, content string) (uint, bool) {
+	limits := config.C.LevelFormat
+
+	parsed, err := levelformat.Parse(content, levelformat.Limits{
+		MaxContentBytes: limits.MaxContentBytes,
+		MaxWidth:        limits.MaxWidth,
+		MaxHeight:       limits.MaxHeight,
+		MaxTiles:        limits.MaxTiles,
+		MaxObjects:      limits.MaxObjects,
+		MaxPathPoints:   limits.MaxPathPoints,
+	})
+
+	if err != nil {
+		var formatErrors levelformat.Errors
+
+		if !errors.As(err, &formatErrors) {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return 0, false
+		}
+
+		if !limits.Enforce && formatErrors[0].Code != levelformat.CodeTooLarge {
+			return 0, true
+		}
+
+		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid level content", "details": formatErrors})
+		return 0, false
+	}
+
+	return parsed.Complexity(), true
 }
 
 type levelValidateParams struct {
@@ -47,61 +177,97 @@ func levelsGetAll(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		filter, page, err := getParams.query()
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		levels := []model.Level{}
 
-		var levelCount int64
-		var err error
+		var tx *gorm.DB
 
 		user := auth.GetJWTUser(context)
 
-		if config.C.Environment != config.EnvironmentProduction || user.Role == model.UserRoleMod || user.Role == model.UserRoleAgent {
-			tx := db.Model(&model.Level{}).Preload(clause.Associations)
+		if config.C.Environment != config.EnvironmentProduction || auth.IsReviewer(user) {
+			tx = levelListQuery(db)
 
 			if getParams.OnlySus == 1 {
-				tx = tx.Where("validation_id is null")
+				tx = tx.Where("levels.state = ?", model.LevelStatePending)
 			}
 
 			if user.Role == model.UserRoleAgent {
 				tx = tx.Where("validation_lock is null OR validation_lock < ?", time.Now().Add(time.Minute*time.Duration(config.C.TokenLifeSpan)))
 			}
+		} else {
+			// players only see published levels, whatever state was asked for
+			filter.State = ""
 
-			tx.Count(&levelCount)
+			tx = review.Public(levelListQuery(db))
+		}
 
-			retrieveTx := tx.Offset(getParams.Offset).
-				Limit(getParams.Limit).
-				Find(&levels)
+		levelCount, next, err := levelquery.Find(levelquery.Apply(tx, filter), page, &levels)
 
-			err = retrieveTx.Error
-		} else {
-			tx := db.
-				Model(&model.Level{}).
-				Preload(clause.Associations).
-				Where("validation_id is not null").
-				Joins("JOIN "+((&model.Validation{}).TableName())+" v ON v.level_id = "+((&model.Level{}).TableName())+".id AND v.result = ?", model.ResultOk)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-			tx.Count(&levelCount)
+		if err := fillLevelDetails(db, user, levels); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-			retrieveTx := tx.Offset(getParams.Offset).
-				Limit(getParams.Limit).
-				Find(&levels)
+		levelPageResponse(context, levels, levelCount, next)
+	}
+}
 
-			err = retrieveTx.Error
+// levelsRanked lists the public levels by a ranking, best first
+func levelsRanked(db *gorm.DB, sort levelquery.SortType) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var getParams levelGetParams
+
+		if err := context.BindQuery(&getParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
+		getParams.Sort = sort
+		getParams.Order = "desc"
+
+		filter, page, err := getParams.query()
+
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				context.Status(http.StatusNotFound)
-				return
-			}
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter.State = ""
+
+		user := auth.GetJWTUser(context)
+		levels := []model.Level{}
+
+		tx := review.Public(levelListQuery(db))
 
+		if sort == levelquery.SortTrending {
+			// levels without votes in the trending window aren't trending at all
+			tx = tx.Where("levels.trending_score > 0")
+		}
+
+		levelCount, next, err := levelquery.Find(levelquery.Apply(tx, filter), page, &levels)
+
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{
-			"levels": levels,
-			"total":  levelCount,
-		})
+		if err := fillLevelDetails(db, user, levels); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		levelPageResponse(context, levels, levelCount, next)
 	}
 }
 
@@ -109,7 +275,7 @@ func levelValidate(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
-		if user.Role != model.UserRoleMod && user.Role != model.UserRoleAgent {
+		if !auth.IsReviewer(user) {
 			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
 			return
 		}
@@ -133,55 +299,37 @@ func levelValidate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var level model.Level
-
-		tx := db.Where("id = ?", user.ID, levelID).First(&level)
-
-		if tx.Error != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": tx.Error})
-			return
+		outcome := review.Outcome{
+			Version:     uint(validateParams.Levelversion),
+			Result:      validateParams.ValidationResult,
+			ValidatorID: user.ID,
+			AuthorScore: validateParams.AuthorScore,
 		}
 
-		validation := model.Validation{
-			LevelVersion: level.Version,
-			Result:       validateParams.ValidationResult,
-			ValidatorID:  user.ID,
-		}
+		// content changed by the validator has to hold up like uploaded content
+		if validateParams.Content != "" {
+			complexity, ok := parseLevelContent(context, validateParams.Content)
 
-		tx = db.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "version"}},
-			UpdateAll: true,
-		}).Create(&validation)
+			if !ok {
+				return
+			}
 
-		if tx.Error != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error})
-			return
+			outcome.Content = validateParams.Content
+			outcome.Complexity = complexity
 		}
 
-		if level.Validation != nil {
-			tx = db.Delete(&level.Validation)
-
-			if tx.Error != nil {
-				/*
-					TODO: Old validation could not be deleted, would should happen ?
-
-					context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error})
-					return
-				*/
+		// rendered up front so a broken image fails the request, stored only if the level is published
+		if len(validateParams.Thumbnail) > 0 {
+			if outcome.Thumbnails, err = thumbnail.Render(levelID, validateParams.Thumbnail); err != nil {
+				thumbnailError(context, err)
+				return
 			}
 		}
 
-		level.Content = validateParams.Content
-		level.Validation = &validation
-		level.AuthorScore = validateParams.AuthorScore
-		level.Thumbnail = validateParams.Thumbnail
-		level.Published = time.Now()
-		level.Version += 1
+		validation, err := review.Validate(db, levelID, outcome)
 
-		tx = db.Save(&level)
-
-		if tx.Error != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error})
+		if err != nil {
+			reviewError(context, err)
 			return
 		}
 
@@ -191,6 +339,18 @@ func levelValidate(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// reviewError answers with the status matching an error of the review package
+func reviewError(context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, review.ErrLevelNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, review.ErrInvalidTransition), errors.Is(err, review.ErrVersionChanged):
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func levelsGetOwn(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
@@ -202,37 +362,30 @@ func levelsGetOwn(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		levels := []model.Level{}
-
-		var levelCount int64
+		filter, page, err := getParams.query()
 
-		tx := db.
-			Model(&model.Level{}).
-			Preload(clause.Associations).
-			Where("user_id = ?", user.ID)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		tx.Count(&levelCount)
+		levels := []model.Level{}
 
-		retrieveTx := tx.Offset(getParams.Offset).
-			Limit(getParams.Limit).
-			Find(&levels)
+		tx := levelListQuery(db).Where("levels.user_id = ?", user.ID)
 
-		err := retrieveTx.Error
+		levelCount, next, err := levelquery.Find(levelquery.Apply(tx, filter), page, &levels)
 
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				context.Status(http.StatusNotFound)
-				return
-			}
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
+		if err := fillLevelDetails(db, user, levels); err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{
-			"levels": levels,
-			"total":  levelCount,
-		})
+		levelPageResponse(context, levels, levelCount, next)
 	}
 }
 
@@ -240,6 +393,10 @@ func levelsAdd(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
+		if abortIfBanned(context, db, user, model.BanScopeUpload) {
+			return
+		}
+
 		var levelAddParams levelParams
 
 		if err := context.BindJSON(&levelAddParams); err != nil {
@@ -247,21 +404,38 @@ func levelsAdd(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		complexity, ok := parseLevelContent(context, levelAddParams.Content)
+
+		if !ok {
+			return
+		}
+
 		level := model.Level{
 			User:         user,
 			Name:         levelAddParams.Name,
 			Content:      levelAddParams.Content,
 			AuthorReplay: levelAddParams.Replay,
+			Difficulty:   levelAddParams.Difficulty,
+			Complexity:   complexity,
 		}
 
-		tx := db.Create(&level)
-
-		if tx.Error != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error})
+		if err := review.Create(db, &level); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{"id": level.ID})
+		if levelAddParams.Submit {
+			state, err := review.Submit(db, user.ID, level.ID)
+
+			if err != nil {
+				reviewError(context, err)
+				return
+			}
+
+			level.State = state
+		}
+
+		context.JSON(http.StatusOK, gin.H{"id": level.ID, "state": level.State})
 	}
 }
 
@@ -282,16 +456,40 @@ func levelsDelete(db *gorm.DB) gin.HandlerFunc {
 
 		tx := db
 
-		if user.Role != model.UserRoleMod && user.Role != model.UserRoleAgent {
+		if !auth.IsReviewer(user) {
 			tx = tx.Where("user_id = ?", user.ID)
 		}
 
-		tx = tx.First(&level)
+		result := tx.Limit(1).Find(&level)
 
-		if level.ID != uuid.Nil {
-			db.Delete(&level)
-		} else {
+		if result.Error != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
 			context.JSON(http.StatusNonAuthoritativeInfo, gin.H{"error": "not authorized to delete this level"})
+			return
+		}
+
+		// everything referring to the level goes with it, blobs are left to the collection job
+		err = db.Transaction(func(tx *gorm.DB) error {
+			dependents := []interface{}{
+				&model.Vote{}, &model.Report{}, &model.Play{}, &model.Score{}, &model.LevelRevision{}, &model.Thumbnail{},
+			}
+
+			for _, m := range dependents {
+				if err := tx.Where("level_id = ?", level.ID).Delete(m).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Delete(&level).Error
+		})
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
 		context.Status(http.StatusOK)
@@ -302,7 +500,7 @@ func lockLevelValidation(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
-		if user.Role == model.UserRoleAgent {
+		if !auth.IsReviewer(user) {
 			context.JSON(http.StatusBadRequest, gin.H{"error": "Not authorized to lock validation for level"})
 			return
 		}
@@ -319,16 +517,16 @@ func lockLevelValidation(db *gorm.DB) gin.HandlerFunc {
 		tx := db.Where("id = ?", levelID).First(&level)
 
 		if tx.Error != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			context.JSON(http.StatusBadRequest, gin.H{"error": tx.Error.Error()})
 			return
 		}
 
-		if level.Validation != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "level is already validated"})
+		if level.State != model.LevelStatePending {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "level is not pending review"})
 			return
 		}
 
-		if level.ValidationLock.Before(time.Now().Add(time.Minute*time.Duration(config.C.TokenLifeSpan))) && user.ID != *level.ValidationAgentID {
+		if level.ValidationAgentID != nil && user.ID != *level.ValidationAgentID && level.ValidationLock.After(time.Now().Add(-time.Minute*time.Duration(config.C.TokenLifeSpan))) {
 			context.JSON(http.StatusBadRequest, gin.H{"error": "is in lock by another agent"})
 			return
 		}
@@ -336,10 +534,10 @@ func lockLevelValidation(db *gorm.DB) gin.HandlerFunc {
 		level.ValidationLock = time.Now()
 		level.ValidationAgentID = &user.ID
 
-		tx = db.Save(level)
+		tx = db.Save(&level)
 
 		if tx.Error != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
 			return
 		}
 
@@ -351,6 +549,10 @@ func levelsUpdate(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
+		if abortIfBanned(context, db, user, model.BanScopeUpload) {
+			return
+		}
+
 		var updateParams levelParams
 
 		levelID, err := uuid.Parse(context.Param("levelId"))
@@ -365,36 +567,335 @@ func levelsUpdate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var level model.Level
+		complexity, ok := parseLevelContent(context, updateParams.Content)
 
-		tx := db.Where("user_id = ? AND id = ?", user.ID, levelID).First(&level)
+		if !ok {
+			return
+		}
 
-		if tx.Error != nil {
+		level, err := review.Revise(db, user.ID, levelID, model.LevelRevision{
+			Name:         updateParams.Name,
+			Content:      updateParams.Content,
+			AuthorReplay: updateParams.Replay,
+			Difficulty:   updateParams.Difficulty,
+			Complexity:   complexity,
+		})
+
+		if err != nil {
+			reviewError(context, err)
+			return
+		}
+
+		if updateParams.Submit {
+			state, err := review.Submit(db, user.ID, levelID)
+
+			if err != nil {
+				reviewError(context, err)
+				return
+			}
+
+			level.State = state
+		}
+
+		context.JSON(http.StatusOK, gin.H{"version": level.Version, "state": level.State})
+	}
+}
+
+func levelSubmit(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if abortIfBanned(context, db, user, model.BanScopeUpload) {
+			return
+		}
+
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
 			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if tx.RowsAffected == 0 {
-			context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		state, err := review.Submit(db, user.ID, levelID)
+
+		if err != nil {
+			reviewError(context, err)
 			return
 		}
 
-		updatedLevel := model.Level{
-			ID:           level.ID,
-			User:         level.User,
-			AuthorReplay: updateParams.Replay,
-			Name:         updateParams.Name,
-			Content:      updateParams.Content,
+		context.JSON(http.StatusOK, gin.H{"state": state})
+	}
+}
+
+type levelHideParams struct {
+	Reason string `json:"reason"`
+}
+
+func levelSetHidden(db *gorm.DB, hidden bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if !auth.IsModerator(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no moderation authorization"})
+			return
 		}
 
-		tx = db.Save(&updatedLevel)
+		levelID, err := uuid.Parse(context.Param("levelId"))
 
-		if tx.Error != nil {
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var hideParams levelHideParams
+
+		if err := context.BindJSON(&hideParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if hidden && hideParams.Reason == "" {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "missing reason"})
+			return
+		}
+
+		if err := review.SetHidden(db, levelID, hidden, user.ID, hideParams.Reason); err != nil {
+			reviewError(context, err)
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// levelRevisionAccess loads the level if the user is its creator or a moderator
+func levelRevisionAccess(context *gin.Context, db *gorm.DB) (*model.Level, bool) {
+	user := auth.GetJWTUser(context)
+
+	levelID, err := uuid.Parse(context.Param("levelId"))
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	var level model.Level
+
+	tx := db.Where("id = ?", levelID).Limit(1).Find(&level)
+
+	if tx.Error != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+		return nil, false
+	}
+
+	if tx.RowsAffected == 0 {
+		context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
+		return nil, false
+	}
+
+	if level.UserID != user.ID && !auth.IsReviewer(user) {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": "no authorization for the versions of this level"})
+		return nil, false
+	}
+
+	return &level, true
+}
+
+func levelVersionsGet(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		level, ok := levelRevisionAccess(context, db)
+
+		if !ok {
+			return
+		}
+
+		revisions, err := revision.List(db, level.ID)
+
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.Status(http.StatusOK)
+		context.JSON(http.StatusOK, gin.H{"versions": revisions})
+	}
+}
+
+func levelVersionGet(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		level, ok := levelRevisionAccess(context, db)
+
+		if !ok {
+			return
+		}
+
+		version, err := strconv.ParseUint(context.Param("version"), 10, 32)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		levelRevision, err := revision.Find(db, level.ID, uint(version))
+
+		if err != nil {
+			if errors.Is(err, revision.ErrRevisionNotFound) {
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := levelcontent.LoadRevision(levelRevision); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, levelRevision)
+	}
+}
+
+// levelReadAccess loads the level of the request with the query tx if the user may see it. Public levels
+// are visible to everyone, others only to their creator and moderators.
+func levelReadAccess(context *gin.Context, tx *gorm.DB) (*model.Level, bool) {
+	user := auth.GetJWTUser(context)
+
+	levelID, err := uuid.Parse(context.Param("levelId"))
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	var level model.Level
+
+	tx = tx.Where("levels.id = ?", levelID).Limit(1).Find(&level)
+
+	if tx.Error != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+		return nil, false
+	}
+
+	// levels the user may not see are answered like missing ones
+	if tx.RowsAffected == 0 || (!review.IsPublic(&level) && level.UserID != user.ID && !auth.IsReviewer(user)) {
+		context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
+		return nil, false
+	}
+
+	return &level, true
+}
+
+// notModified sets the etag and answers 304 if the client already has this version
+func notModified(context *gin.Context, etag string) bool {
+	etag = `"` + etag + `"`
+
+	context.Header("ETag", etag)
+	context.Header("Cache-Control", "private, no-cache")
+
+	if context.GetHeader("If-None-Match") == etag {
+		context.Status(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// levelDetailQuery selects a level with everything but content and replay
+func levelDetailQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.Level{}).Preload(clause.Associations).Omit("content", "author_replay", "thumbnail")
+}
+
+// levelDetailResponse sends the details of the level with an etag of the response
+func levelDetailResponse(context *gin.Context, db *gorm.DB, level *model.Level) {
+	levels := []model.Level{*level}
+
+	if err := fillLevelDetails(db, auth.GetJWTUser(context), levels); err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := json.Marshal(levels[0])
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if notModified(context, blob.Key(body)) {
+		return
+	}
+
+	context.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// levelGet returns the details of a level, content and replay are fetched separately
+func levelGet(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		level, ok := levelReadAccess(context, levelDetailQuery(db))
+
+		if !ok {
+			return
+		}
+
+		levelDetailResponse(context, db, level)
+	}
+}
+
+// levelGetByCode returns the details of the public level with the share code
+func levelGetByCode(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		level, err := review.FindByShareCode(review.Public(levelDetailQuery(db)), context.Param("code"))
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sharecode.ErrInvalid):
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, review.ErrLevelNotFound):
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+
+			return
+		}
+
+		levelDetailResponse(context, db, level)
+	}
+}
+
+// levelData serves the content or the replay of the current revision of a level, the blob key is the etag
+func levelData(db *gorm.DB, replay bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		level, ok := levelReadAccess(context, db.Model(&model.Level{}))
+
+		if !ok {
+			return
+		}
+
+		key, inline, contentType := level.ContentKey, level.Content, "application/json"
+
+		if replay {
+			key, inline, contentType = level.AuthorReplayKey, level.AuthorReplay, "application/octet-stream"
+		}
+
+		if key == "" && inline == "" {
+			context.JSON(http.StatusNotFound, gin.H{"error": "level has no such data"})
+			return
+		}
+
+		if notModified(context, levelcontent.Key(key, inline)) {
+			return
+		}
+
+		data, err := levelcontent.Read(key, inline)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.Data(http.StatusOK, contentType, []byte(data))
 	}
 }
 
@@ -402,6 +903,10 @@ func levelVote(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
+		if abortIfBanned(context, db, user, model.BanScopeVote) {
+			return
+		}
+
 		levelID, err := uuid.Parse(context.Param("levelId"))
 
 		if err != nil {
@@ -416,36 +921,53 @@ func levelVote(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if voteParams.VoteType == "" {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "missing vote type"})
+		vote, err := voting.Cast(db, user.ID, levelID, voteParams.VoteType)
+
+		if err != nil {
+			if errors.Is(err, voting.ErrInvalidVoteType) {
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if errors.Is(err, voting.ErrLevelNotFound) {
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		var level model.Level
-		tx := db.Limit(1).Where("user_id!=? AND id = ?", user.ID, levelID).Find(&level)
+		context.JSON(http.StatusOK, gin.H{"voteID": vote.ID})
+	}
+}
 
-		if tx.Error != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
+func levelRetractVote(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if abortIfBanned(context, db, user, model.BanScopeVote) {
 			return
 		}
 
-		vote := model.Vote{
-			User:  user,
-			Level: &level,
-			Type:  voteParams.VoteType,
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		tx = db.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "user_id"}, {Name: "level_id"}},
-			UpdateAll: true,
-		}).Create(&vote)
+		if err := voting.Retract(db, user.ID, levelID); err != nil {
+			if errors.Is(err, voting.ErrLevelNotFound) || errors.Is(err, voting.ErrNoVote) {
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
 
-		if tx.Error != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{"voteID": vote.ID})
+		context.Status(http.StatusNoContent)
 	}
 }
 
@@ -453,6 +975,10 @@ func levelReport(db *gorm.DB) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		user := auth.GetJWTUser(context)
 
+		if abortIfBanned(context, db, user, model.BanScopeReport) {
+			return
+		}
+
 		levelID, err := uuid.Parse(context.Param("levelId"))
 
 		if err != nil {
@@ -461,7 +987,7 @@ func levelReport(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var level model.Level
-		tx := db.Where("user_id != ? AND id = ?", user.ID, levelID).First(&level)
+		tx := review.Public(db).Where("user_id != ? AND id = ?", user.ID, levelID).First(&level)
 
 		if tx.Error != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
@@ -493,9 +1019,15 @@ func UseLevel(router gin.IRouter, db *gorm.DB) {
 	levelRouter := router.Group("/levels")
 
 	levelRouter.GET("", levelsGetAll(db))
+	levelRouter.GET("/trending", levelsRanked(db, levelquery.SortTrending))
+	levelRouter.GET("/hot", levelsRanked(db, levelquery.SortHot))
 	//levelRouter.GET("/sus", levelsGetAllSus(db))
 	router.GET("me/levels", levelsGetOwn(db))
 
+	levelRouter.GET("/by-code/:code", levelGetByCode(db))
+	levelRouter.GET("/:levelId", levelGet(db))
+	levelRouter.GET("/:levelId/content", levelData(db, false))
+	levelRouter.GET("/:levelId/replay", levelData(db, true))
 	levelRouter.DELETE("/:levelId", levelsDelete(db))
 
 	levelRouter.POST("", levelsAdd(db))
@@ -503,6 +1035,14 @@ func UseLevel(router gin.IRouter, db *gorm.DB) {
 	levelRouter.PUT("/:levelId", levelsUpdate(db))
 	levelRouter.PUT("/:levelId/reports", levelReport(db))
 	levelRouter.PUT("/:levelId/vote", levelVote(db))
+	levelRouter.DELETE("/:levelId/vote", levelRetractVote(db))
 	levelRouter.PUT("/:levelId/validate", levelValidate(db))
 	levelRouter.PUT("/:levelId/lock", lockLevelValidation(db))
+	levelRouter.POST("/:levelId/submit", levelSubmit(db))
+	levelRouter.PUT("/:levelId/thumbnail", levelThumbnailUpload(db))
+	levelRouter.POST("/:levelId/hide", levelSetHidden(db, true))
+	levelRouter.POST("/:levelId/unhide", levelSetHidden(db, false))
+
+	levelRouter.GET("/:levelId/versions", levelVersionsGet(db))
+	levelRouter.GET("/:levelId/versions/:version", levelVersionGet(db))
 }
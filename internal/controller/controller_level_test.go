@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseLevelContent(t *testing.T) {
+	previous := config.C.LevelFormat
+	t.Cleanup(func() { config.C.LevelFormat = previous })
+
+	valid := `{"formatVersion": 1, "width": 8, "height": 8, "spawn": {"x": 1, "y": 1}, "goal": {"x": 6, "y": 1},
+		"tiles": [{"x": 0, "y": 0, "type": "ground"}], "objects": []}`
+
+	tests := []struct {
+		name       string
+		enforce    bool
+		maxBytes   int
+		content    string
+		ok         bool
+		complexity uint
+	}{
+		{"valid", true, 0, valid, true, 1},
+		{"valid without enforcing", false, 0, valid, true, 1},
+		{"unknown format", true, 0, `{"blocks": []}`, false, 0},
+		{"unknown format without enforcing", false, 0, `{"blocks": []}`, true, 0},
+		{"too large without enforcing", false, 8, `{"blocks": []}`, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.C.LevelFormat = config.LevelFormat{Enforce: tt.enforce, MaxContentBytes: tt.maxBytes}
+
+			gin.SetMode(gin.TestMode)
+
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+
+			complexity, ok := parseLevelContent(context, tt.content)
+
+			if ok != tt.ok || complexity != tt.complexity {
+				t.Errorf("got %d, %v, want %d, %v", complexity, ok, tt.complexity, tt.ok)
+			}
+
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/internal/play"
+	"github.com/Lyretto/spooky-bodies-golang/internal/ratelimit"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type playFinishParams struct {
+	Outcome model.PlayOutcome `json:"outcome"`
+	TimeMs  uint              `json:"timeMs"`
+	Deaths  uint              `json:"deaths"`
+}
+
+// playError answers with the status matching an error of the play package
+func playError(context *gin.Context, err error) {
+	var limitedErr *ratelimit.LimitedError
+
+	switch {
+	case errors.Is(err, play.ErrLevelNotFound), errors.Is(err, play.ErrPlayNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, play.ErrInvalidOutcome), errors.Is(err, play.ErrInvalidTime):
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, play.ErrPlayFinished):
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &limitedErr):
+		ratelimit.Abort(context, limitedErr)
+	default:
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// levelPlayStart starts a play session, the game finishes it with the returned id
+func levelPlayStart(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		started, err := play.Start(db, user.ID, levelID)
+
+		if err != nil {
+			playError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusCreated, gin.H{"playId": started.ID})
+	}
+}
+
+func playFinish(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		playID, err := uuid.Parse(context.Param("playId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var finishParams playFinishParams
+
+		if err := context.BindJSON(&finishParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		finished, err := play.Finish(
+			db,
+			user.ID,
+			playID,
+			finishParams.Outcome,
+			time.Duration(finishParams.TimeMs)*time.Millisecond,
+			finishParams.Deaths,
+		)
+
+		if err != nil {
+			playError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, finished)
+	}
+}
+
+func UsePlay(router gin.IRouter, db *gorm.DB) {
+	router.POST("/levels/:levelId/plays", levelPlayStart(db))
+	router.PUT("/plays/:playId", playFinish(db))
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/account"
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func privacyExport(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		var archive bytes.Buffer
+
+		if err := account.Export(db, user, &archive); err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		fileName := "spooky-bodies-export-" + time.Now().Format("2006-01-02") + ".zip"
+
+		context.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		context.Data(http.StatusOK, "application/zip", archive.Bytes())
+	}
+}
+
+func privacyRequestDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		request, err := account.RequestDeletion(db, user)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusAccepted, gin.H{"deletion": request})
+	}
+}
+
+func privacyGetDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		request, err := account.FindDeletionRequest(db, user.ID)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if request == nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": account.ErrNoDeletionRequest.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"deletion": request})
+	}
+}
+
+func privacyCancelDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if err := account.CancelDeletion(db, user.ID); err != nil {
+			if errors.Is(err, account.ErrNoDeletionRequest) {
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func UsePrivacy(router gin.IRouter, db *gorm.DB) {
+	router.GET("/me/export", privacyExport(db))
+	router.DELETE("/me", privacyRequestDeletion(db))
+	router.GET("/me/deletion", privacyGetDeletion(db))
+	router.DELETE("/me/deletion", privacyCancelDeletion(db))
+}
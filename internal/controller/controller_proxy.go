@@ -0,0 +1,14 @@
+package controller
+
+import (
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// UseTrustedProxies limits which proxies may set the client ip. By default gin trusts every proxy,
+// so any client could rotate X-Forwarded-For to escape the per ip rate limits.
+func UseTrustedProxies(router *gin.Engine) error {
+	router.TrustedPlatform = config.C.Proxy.TrustedPlatform
+
+	return router.SetTrustedProxies(config.C.Proxy.TrustedProxies)
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// clientIP returns the ip the login rate limits are keyed on for a request from remoteAddr
+func clientIP(t *testing.T, remoteAddr string, forwardedFor string) string {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+
+	if err := UseTrustedProxies(router); err != nil {
+		t.Fatal(err)
+	}
+
+	var ip string
+
+	router.GET("/", func(c *gin.Context) {
+		ip = c.ClientIP()
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = remoteAddr
+
+	if forwardedFor != "" {
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	return ip
+}
+
+func TestUseTrustedProxies(t *testing.T) {
+	previous := config.C.Proxy
+	t.Cleanup(func() { config.C.Proxy = previous })
+
+	config.C.Proxy = config.Proxy{TrustedProxies: []string{"172.16.0.0/12"}}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"spoofed without proxy", "203.0.113.5:4000", "198.51.100.7", "203.0.113.5"},
+		{"through proxy", "172.18.0.2:4000", "198.51.100.7", "198.51.100.7"},
+		{"spoofed through proxy", "172.18.0.2:4000", "192.0.2.1, 198.51.100.7", "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(t, tt.remoteAddr, tt.forwardedFor); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseTrustedProxiesNone(t *testing.T) {
+	previous := config.C.Proxy
+	t.Cleanup(func() { config.C.Proxy = previous })
+
+	config.C.Proxy = config.Proxy{}
+
+	if got := clientIP(t, "172.18.0.2:4000", "198.51.100.7"); got != "172.18.0.2" {
+		t.Errorf("got %s, want the remote address", got)
+	}
+}
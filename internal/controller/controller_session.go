@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type sessionsDeleteParams struct {
+	ExceptCurrent int `form:"except_current"`
+}
+
+func sessionsGetOwn(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+		current := auth.GetUserToken(context)
+
+		sessions := []model.UserToken{}
+
+		tx := db.
+			Where("user_id = ? AND refresh_valid_until > ?", user.ID, time.Now()).
+			Order("last_used_at desc").
+			Find(&sessions)
+
+		if tx.Error != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+			return
+		}
+
+		for i := range sessions {
+			sessions[i].Current = current != nil && sessions[i].ID == current.ID
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"sessions": sessions,
+		})
+	}
+}
+
+func sessionDelete(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		sessionID, err := uuid.Parse(context.Param("sessionId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx := db.Where("user_id = ? AND id = ?", user.ID, sessionID).Delete(&model.UserToken{})
+
+		if tx.Error != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+			return
+		}
+
+		if tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// sessionsDeleteAll logs the user out everywhere, optionally keeping the session of the request
+func sessionsDeleteAll(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		var deleteParams sessionsDeleteParams
+
+		if err := context.BindQuery(&deleteParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx := db.Where("user_id = ?", user.ID)
+
+		if current := auth.GetUserToken(context); deleteParams.ExceptCurrent == 1 && current != nil {
+			tx = tx.Where("id != ?", current.ID)
+		}
+
+		tx = tx.Delete(&model.UserToken{})
+
+		if tx.Error != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"revoked": tx.RowsAffected,
+		})
+	}
+}
+
+func UseSession(router gin.IRouter, db *gorm.DB) {
+	sessionRouter := router.Group("/me/sessions")
+
+	sessionRouter.GET("", sessionsGetOwn(db))
+	sessionRouter.DELETE("", sessionsDeleteAll(db))
+	sessionRouter.DELETE("/:sessionId", sessionDelete(db))
+}
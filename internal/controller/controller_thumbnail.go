@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/thumbnail"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// thumbnailError answers with the status matching an error of the thumbnail package
+func thumbnailError(context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, thumbnail.ErrTooLarge):
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, thumbnail.ErrUnsupportedFormat), errors.Is(err, thumbnail.ErrInvalidDimensions):
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// levelThumbnailUpload takes the raw image as request body, the format is detected from the content.
+// Creators of published levels only stage a thumbnail, it goes live with their next validated revision.
+func levelThumbnailUpload(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if abortIfBanned(context, db, user, model.BanScopeUpload) {
+			return
+		}
+
+		levelID, err := uuid.Parse(context.Param("levelId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var level model.Level
+
+		tx := db.Select("id", "user_id", "published_version").Where("id = ?", levelID).Limit(1).Find(&level)
+
+		if tx.Error != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+			return
+		}
+
+		if tx.RowsAffected == 0 {
+			context.JSON(http.StatusNotFound, gin.H{"error": "level not found"})
+			return
+		}
+
+		if level.UserID != user.ID && !auth.IsReviewer(user) {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no authorization for the thumbnail of this level"})
+			return
+		}
+
+		var body io.Reader = context.Request.Body
+
+		// read one byte more than allowed to tell a too large upload from one of exactly the limit,
+		// 0 disables the limit like in the thumbnail package
+		if maxBytes := config.C.Thumbnails.MaxBytes; maxBytes > 0 {
+			body = io.LimitReader(body, int64(maxBytes)+1)
+		}
+
+		data, err := io.ReadAll(body)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pending := level.PublishedVersion > 0 && !auth.IsReviewer(user)
+
+		thumbnails, err := thumbnail.Store(db, level.ID, data, pending)
+
+		if err != nil {
+			thumbnailError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"thumbnails": thumbnail.URLs(thumbnails), "pending": pending})
+	}
+}
+
+func thumbnailGet(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		thumbnailID, err := uuid.Parse(context.Param("thumbnailId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		etag := `"` + thumbnailID.String() + `"`
+
+		// thumbnails never change, a known id is always up to date
+		if context.GetHeader("If-None-Match") == etag {
+			context.Status(http.StatusNotModified)
+			return
+		}
+
+		found, err := thumbnail.Find(db, thumbnailID)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if found == nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "thumbnail not found"})
+			return
+		}
+
+		context.Header("Cache-Control", "public, max-age="+strconv.Itoa(config.C.Thumbnails.CacheMaxAge)+", immutable")
+		context.Header("ETag", etag)
+		context.Data(http.StatusOK, found.ContentType, found.Data)
+	}
+}
+
+// UseThumbnail serves thumbnails without authentication so clients and CDNs can cache them,
+// it has to be registered before UseAuth. Thumbnail ids are random and only handed out with levels.
+func UseThumbnail(router gin.IRouter, db *gorm.DB) {
+	router.GET("/thumbnails/:thumbnailId", thumbnailGet(db))
+}
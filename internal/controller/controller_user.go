@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/admin"
+	"github.com/Lyretto/spooky-bodies-golang/internal/auth"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelquery"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type userRoleParams struct {
+	Role   model.UserRole `json:"role"`
+	Reason string         `json:"reason"`
+}
+
+type auditGetParams struct {
+	TargetID string `form:"target_id"`
+	Offset   int    `form:"offset"`
+	Limit    int    `form:"limit"`
+}
+
+func userSetRole(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if user.Role != model.UserRoleAdmin {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no admin authorization"})
+			return
+		}
+
+		userID, err := uuid.Parse(context.Param("userId"))
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var roleParams userRoleParams
+
+		if err := context.BindJSON(&roleParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updatedUser, err := admin.SetUserRole(db, userID, roleParams.Role, &user.ID, model.AuditSourceAPI, roleParams.Reason)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, admin.ErrInvalidRole):
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, admin.ErrUserNotFound):
+				context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"id":   updatedUser.ID,
+			"role": roleParams.Role,
+		})
+	}
+}
+
+func auditGetAll(db *gorm.DB) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user := auth.GetJWTUser(context)
+
+		if user.Role != model.UserRoleAdmin {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "no admin authorization"})
+			return
+		}
+
+		var getParams auditGetParams
+
+		if err := context.BindQuery(&getParams); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries := []model.AuditEntry{}
+
+		tx := db.Model(&model.AuditEntry{})
+
+		if getParams.TargetID != "" {
+			targetID, err := uuid.Parse(getParams.TargetID)
+
+			if err != nil {
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			tx = tx.Where("target_id = ?", targetID)
+		}
+
+		var entryCount int64
+
+		if err := tx.Count(&entryCount).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		limit := levelquery.PageSize(getParams.Limit)
+
+		if err := tx.Order("created_at desc").Offset(getParams.Offset).Limit(limit).Find(&entries).Error; err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"entries": entries,
+			"total":   entryCount,
+		})
+	}
+}
+
+func UseUser(router gin.IRouter, db *gorm.DB) {
+	router.PUT("/users/:userId/role", userSetRole(db))
+	router.GET("/audit", auditGetAll(db))
+}
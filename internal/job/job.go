@@ -0,0 +1,156 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Func is the work of a job. It runs inside a transaction holding the job's advisory lock.
+type Func func(tx *gorm.DB) error
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      Func
+}
+
+// Scheduler runs registered jobs periodically. Every run takes a postgres advisory lock
+// derived from the job name, so with multiple replicas only one of them runs a job at a time.
+type Scheduler struct {
+	db     *gorm.DB
+	jobs   []Job
+	host   string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(db *gorm.DB) *Scheduler {
+	host, err := os.Hostname()
+
+	if err != nil {
+		host = "unknown"
+	}
+
+	return &Scheduler{
+		db:   db,
+		host: host,
+	}
+}
+
+// Add registers a job, jobs with an interval of zero or less are disabled
+func (s *Scheduler) Add(name string, interval time.Duration, run Func) {
+	if interval <= 0 {
+		return
+	}
+
+	s.jobs = append(s.jobs, Job{
+		Name:     name,
+		Interval: interval,
+		Run:      run,
+	})
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(j); err != nil {
+			fmt.Printf("job %s failed: %s\n", j.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("job:" + name))
+
+	return int64(h.Sum64())
+}
+
+// RunOnce runs the job if no other replica currently does and records the outcome
+func (s *Scheduler) RunOnce(j Job) error {
+	started := time.Now()
+	locked := false
+
+	runErr := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey(j.Name)).Scan(&locked).Error; err != nil {
+			return err
+		}
+
+		if !locked {
+			return nil
+		}
+
+		return j.Run(tx)
+	})
+
+	if !locked && runErr == nil {
+		return nil
+	}
+
+	run := model.JobRun{
+		Name:         j.Name,
+		LastRunAt:    started,
+		LastDuration: time.Since(started).Milliseconds(),
+		LastRunBy:    s.host,
+		Runs:         1,
+	}
+
+	if runErr != nil {
+		run.LastError = runErr.Error()
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_run_at":   run.LastRunAt,
+			"last_duration": run.LastDuration,
+			"last_error":    run.LastError,
+			"last_run_by":   run.LastRunBy,
+			"runs":          gorm.Expr("job_runs.runs + 1"),
+		}),
+	}).Create(&run).Error
+
+	if runErr != nil {
+		return runErr
+	}
+
+	return err
+}
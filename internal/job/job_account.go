@@ -0,0 +1,12 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/account"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+)
+
+func UseAccount(scheduler *Scheduler) {
+	scheduler.Add("execute-account-deletions", time.Minute*time.Duration(config.C.Jobs.AccountDeletionInterval), account.ExecuteDeletions)
+}
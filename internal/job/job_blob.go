@@ -0,0 +1,47 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"gorm.io/gorm"
+)
+
+// blobGrace keeps recently written blobs, the rows referring to them may not be committed yet
+const blobGrace = time.Hour
+
+// CollectBlobs deletes stored content, replays and thumbnails no level, revision, thumbnail or score refers to anymore
+func CollectBlobs(tx *gorm.DB) error {
+	_, err := blob.Collect(blobGrace, func(keys []string) (map[string]bool, error) {
+		var found []string
+
+		err := tx.Raw(`
+			SELECT content_key FROM levels WHERE content_key IN @keys
+			UNION SELECT author_replay_key FROM levels WHERE author_replay_key IN @keys
+			UNION SELECT content_key FROM level_revisions WHERE content_key IN @keys
+			UNION SELECT author_replay_key FROM level_revisions WHERE author_replay_key IN @keys
+			UNION SELECT blob_key FROM thumbnails WHERE blob_key IN @keys
+			UNION SELECT replay_key FROM scores WHERE replay_key IN @keys`,
+			map[string]interface{}{"keys": keys},
+		).Scan(&found).Error
+
+		if err != nil {
+			return nil, err
+		}
+
+		referenced := make(map[string]bool, len(found))
+
+		for _, key := range found {
+			referenced[key] = true
+		}
+
+		return referenced, nil
+	})
+
+	return err
+}
+
+func UseBlob(scheduler *Scheduler) {
+	scheduler.Add("collect-blobs", time.Minute*time.Duration(config.C.Jobs.BlobCollectionInterval), CollectBlobs)
+}
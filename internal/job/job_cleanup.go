@@ -0,0 +1,36 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"gorm.io/gorm"
+)
+
+// CleanupExpiredTokens deletes sessions whose access and refresh token both expired
+func CleanupExpiredTokens(tx *gorm.DB) error {
+	now := time.Now()
+
+	return tx.
+		Where("valid_until < ? AND refresh_valid_until < ?", now, now).
+		Delete(&model.UserToken{}).Error
+}
+
+// CleanupValidationLocks releases validation locks of agents that didn't validate the level in time
+func CleanupValidationLocks(tx *gorm.DB) error {
+	staleBefore := time.Now().Add(-time.Minute * time.Duration(config.C.TokenLifeSpan))
+
+	return tx.
+		Model(&model.Level{}).
+		Where("validation_agent_id is not null AND validation_lock < ?", staleBefore).
+		Updates(map[string]interface{}{
+			"validation_lock":     time.Time{},
+			"validation_agent_id": nil,
+		}).Error
+}
+
+func UseCleanup(scheduler *Scheduler) {
+	scheduler.Add("cleanup-expired-tokens", time.Minute*time.Duration(config.C.Jobs.TokenCleanupInterval), CleanupExpiredTokens)
+	scheduler.Add("cleanup-validation-locks", time.Minute*time.Duration(config.C.Jobs.ValidationLockCleanupInterval), CleanupValidationLocks)
+}
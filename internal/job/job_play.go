@@ -0,0 +1,12 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/play"
+)
+
+func UsePlay(scheduler *Scheduler) {
+	scheduler.Add("reconcile-play-counts", time.Minute*time.Duration(config.C.Jobs.PlayReconcileInterval), play.ReconcileCounters)
+}
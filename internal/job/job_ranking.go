@@ -0,0 +1,12 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/ranking"
+)
+
+func UseRanking(scheduler *Scheduler) {
+	scheduler.Add("refresh-rankings", time.Minute*time.Duration(config.C.Jobs.RankingRefreshInterval), ranking.Refresh)
+}
@@ -0,0 +1,12 @@
+package job
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/voting"
+)
+
+func UseVoting(scheduler *Scheduler) {
+	scheduler.Add("reconcile-vote-counts", time.Minute*time.Duration(config.C.Jobs.VoteReconcileInterval), voting.ReconcileCounters)
+}
@@ -0,0 +1,265 @@
+package leaderboard
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/internal/review"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLevelNotFound = errors.New("level not found")
+var ErrScoreNotFound = errors.New("score not found")
+var ErrReplayTooLarge = errors.New("replay too large")
+
+// RejectedError is returned for submissions the verifier rejected
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "score rejected: " + e.Reason
+}
+
+type Scope = string
+
+const ScopeGlobal = Scope("global")
+const ScopeFriends = Scope("friends")
+const ScopeAround = Scope("around")
+
+var ErrUnknownScope = errors.New("unknown leaderboard scope")
+
+// Entry is a row of a leaderboard. Rank is shared by equal scores, earlier scores are listed first.
+type Entry struct {
+	ScoreID     uuid.UUID `json:"scoreId"`
+	UserID      uuid.UUID `json:"userId"`
+	Name        string    `json:"name"`
+	Score       int       `json:"score"`
+	TimeMs      uint      `json:"timeMs"`
+	Rank        int64     `json:"rank"`
+	Position    int64     `json:"-"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// findLevel loads the public level scores are submitted for or ranked on
+func findLevel(tx *gorm.DB, levelID uuid.UUID) (*model.Level, error) {
+	var level model.Level
+
+	result := review.Public(tx).Where("id = ?", levelID).Limit(1).Find(&level)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrLevelNotFound
+	}
+
+	return &level, nil
+}
+
+// MigrateScoreIndex drops the unique index on level and user, which let a flagged score replace the accepted one
+func MigrateScoreIndex(db *gorm.DB) error {
+	return db.Exec("DROP INDEX IF EXISTS idx_scores_level_user").Error
+}
+
+// Submit verifies the score of the user and keeps it if it beats the best score of the user with the
+// same status on the published version of the level. improved reports whether the score was kept.
+func Submit(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID, score int, timeMs uint, replay string) (*model.Score, bool, error) {
+	if maxBytes := config.C.Leaderboard.MaxReplayBytes; maxBytes > 0 && len(replay) > maxBytes {
+		return nil, false, ErrReplayTooLarge
+	}
+
+	level, err := findLevel(db, levelID)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	// authors have their own score on the level
+	if level.UserID == userID {
+		return nil, false, ErrLevelNotFound
+	}
+
+	if err := levelcontent.LoadLevel(level); err != nil {
+		return nil, false, err
+	}
+
+	result, err := verifier.Verify(&Submission{
+		Level:   level,
+		Content: level.Content,
+		Score:   score,
+		TimeMs:  timeMs,
+		Replay:  replay,
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if result.Verdict == VerdictReject {
+		return nil, false, &RejectedError{Reason: result.Reason}
+	}
+
+	status := model.ScoreStatusAccepted
+
+	if result.Verdict == VerdictFlag {
+		status = model.ScoreStatusFlagged
+	}
+
+	best := model.Score{
+		LevelID:    levelID,
+		UserID:     userID,
+		Version:    level.PublishedVersion,
+		Score:      score,
+		TimeMs:     timeMs,
+		ReplayKey:  blob.Key([]byte(replay)),
+		Status:     status,
+		FlagReason: result.Reason,
+	}
+
+	improved := false
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// concurrent first submissions both insert, the conflict decides which score stays.
+		// Scores of an older version don't count against the current one.
+		upsert := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "level_id"}, {Name: "user_id"}, {Name: "status"}},
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "scores.version != excluded.version OR scores.score < excluded.score"},
+			}},
+			DoUpdates: clause.AssignmentColumns([]string{"version", "score", "time_ms", "replay_key", "flag_reason", "updated_at"}),
+		}).Create(&best)
+
+		if upsert.Error != nil {
+			return upsert.Error
+		}
+
+		if upsert.RowsAffected == 0 {
+			return tx.Where("level_id = ? AND user_id = ? AND status = ?", levelID, userID, status).Take(&best).Error
+		}
+
+		improved = true
+
+		// only replays of kept scores are stored
+		_, err := blob.Put([]byte(replay), "application/octet-stream")
+
+		return err
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &best, improved, nil
+}
+
+// ranked numbers the ranked scores of the published version of the level
+func ranked(db *gorm.DB, level *model.Level) *gorm.DB {
+	return db.Model(&model.Score{}).
+		Select(`scores.id AS score_id, scores.user_id, users.platform_name AS name, scores.score, scores.time_ms,
+			scores.updated_at AS submitted_at,
+			RANK() OVER (ORDER BY scores.score DESC) AS rank,
+			ROW_NUMBER() OVER (ORDER BY scores.score DESC, scores.updated_at ASC) AS position`).
+		Joins("JOIN users ON users.id = scores.user_id").
+		Where("scores.level_id = ? AND scores.version = ? AND scores.status = ?", level.ID, level.PublishedVersion, model.ScoreStatusAccepted)
+}
+
+// Query describes a leaderboard request. Friends are the platform user ids of the friends of the
+// user on the platform, the user is always part of the friends leaderboard.
+type Query struct {
+	LevelID  uuid.UUID
+	UserID   uuid.UUID
+	Scope    Scope
+	Size     int
+	Platform model.PlatformType
+	Friends  []string
+}
+
+// Find returns the leaderboard of the scope and the entry of the user, which is nil without score
+func Find(db *gorm.DB, query Query) ([]Entry, *Entry, error) {
+	level, err := findLevel(db, query.LevelID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	table := func() *gorm.DB {
+		return db.Table("(?) AS r", ranked(db, level))
+	}
+
+	var mine *Entry
+	own := []Entry{}
+
+	if err := table().Where("r.user_id = ?", query.UserID).Limit(1).Scan(&own).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if len(own) > 0 {
+		mine = &own[0]
+	}
+
+	tx := table()
+
+	switch query.Scope {
+	case ScopeGlobal:
+	case ScopeFriends:
+		friends := db.Model(&model.UserIdentity{}).
+			Select("user_id").
+			Where("platform_type = ? AND platform_user_id IN ?", query.Platform, query.Friends)
+
+		tx = tx.Where("r.user_id = ? OR r.user_id IN (?)", query.UserID, friends)
+	case ScopeAround:
+		if mine == nil {
+			return []Entry{}, nil, nil
+		}
+
+		// the user in the middle, more entries below if the user is near the top
+		from := mine.Position - int64(query.Size/2)
+
+		if from < 1 {
+			from = 1
+		}
+
+		tx = tx.Where("r.position >= ?", from)
+	default:
+		return nil, nil, ErrUnknownScope
+	}
+
+	entries := []Entry{}
+
+	if err := tx.Order("r.position").Limit(query.Size).Scan(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return entries, mine, nil
+}
+
+// FindRanked returns a score shown on a leaderboard, flagged scores and scores of other
+// versions aren't public and neither are their replays
+func FindRanked(db *gorm.DB, scoreID uuid.UUID) (*model.Score, error) {
+	var score model.Score
+
+	result := db.Joins("JOIN levels ON levels.id = scores.level_id AND levels.published_version = scores.version").
+		Where("scores.id = ? AND scores.status = ?", scoreID, model.ScoreStatusAccepted).
+		Where("levels.state != ?", model.LevelStateHidden).
+		Select("scores.*").
+		Limit(1).
+		Find(&score)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrScoreNotFound
+	}
+
+	return &score, nil
+}
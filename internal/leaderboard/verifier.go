@@ -0,0 +1,69 @@
+package leaderboard
+
+import (
+	"fmt"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+)
+
+type Verdict = string
+
+const VerdictAccept = Verdict("accept")
+const VerdictFlag = Verdict("flag")
+const VerdictReject = Verdict("reject")
+
+// Submission is a score submitted by a player together with the replay it was reached with
+type Submission struct {
+	Level   *model.Level
+	Content string
+	Score   int
+	TimeMs  uint
+	Replay  string
+}
+
+// Result is the verdict on a submission, the reason tells players and moderators what was wrong
+type Result struct {
+	Verdict Verdict
+	Reason  string
+}
+
+// Verifier checks submitted replays. A verifier that simulates the game can replay the inputs
+// against the level content, the default one only rejects what is obviously impossible.
+type Verifier interface {
+	Verify(submission *Submission) (Result, error)
+}
+
+var verifier Verifier = PlausibilityVerifier{}
+
+// UseVerifier replaces the replay verifier
+func UseVerifier(v Verifier) {
+	verifier = v
+}
+
+// PlausibilityVerifier checks scores against the configured limits and the author score
+type PlausibilityVerifier struct{}
+
+func (PlausibilityVerifier) Verify(submission *Submission) (Result, error) {
+	limits := config.C.Leaderboard
+
+	if submission.Replay == "" {
+		return Result{VerdictReject, "missing replay"}, nil
+	}
+
+	if submission.Score < 0 || (limits.MaxScore > 0 && submission.Score > limits.MaxScore) {
+		return Result{VerdictReject, fmt.Sprintf("score %d out of range", submission.Score)}, nil
+	}
+
+	if submission.TimeMs == 0 {
+		return Result{VerdictReject, "missing play time"}, nil
+	}
+
+	authorScore := submission.Level.AuthorScore
+
+	if limits.FlagFactor > 0 && authorScore > 0 && float64(submission.Score) > float64(authorScore)*limits.FlagFactor {
+		return Result{VerdictFlag, fmt.Sprintf("score %d far above author score %d", submission.Score, authorScore)}, nil
+	}
+
+	return Result{VerdictAccept, ""}, nil
+}
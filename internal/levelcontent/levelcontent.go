@@ -0,0 +1,197 @@
+package levelcontent
+
+import (
+	"fmt"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"gorm.io/gorm"
+)
+
+const contentType = "application/json"
+const replayContentType = "application/octet-stream"
+
+// put stores non empty data as blob, empty data has no key
+func put(data string, contentType string) (string, error) {
+	if data == "" {
+		return "", nil
+	}
+
+	return blob.Put([]byte(data), contentType)
+}
+
+// Read returns the blob of the key or the inline data of rows written before blobs were used
+func Read(key string, inline string) (string, error) {
+	if key == "" {
+		return inline, nil
+	}
+
+	data, err := blob.Get(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Key is the blob key of the data, for rows written before blobs were used it's computed from the inline data
+func Key(key string, inline string) string {
+	if key != "" {
+		return key
+	}
+
+	return blob.Key([]byte(inline))
+}
+
+// Externalise moves content and replay of the revision into the blob store and keeps only their keys
+func Externalise(revision *model.LevelRevision) error {
+	var err error
+
+	if revision.ContentKey, err = put(revision.Content, contentType); err != nil {
+		return err
+	}
+
+	if revision.AuthorReplayKey, err = put(revision.AuthorReplay, replayContentType); err != nil {
+		return err
+	}
+
+	revision.Content = ""
+	revision.AuthorReplay = ""
+
+	return nil
+}
+
+// ExternaliseLevel moves content and replay of the level into the blob store and keeps only their keys
+func ExternaliseLevel(level *model.Level) error {
+	var err error
+
+	if level.ContentKey, err = put(level.Content, contentType); err != nil {
+		return err
+	}
+
+	if level.AuthorReplayKey, err = put(level.AuthorReplay, replayContentType); err != nil {
+		return err
+	}
+
+	level.Content = ""
+	level.AuthorReplay = ""
+
+	return nil
+}
+
+// ContentKey stores content and returns its key
+func ContentKey(content string) (string, error) {
+	return put(content, contentType)
+}
+
+// LoadRevision fills content and replay of the revision from the blob store
+func LoadRevision(revision *model.LevelRevision) error {
+	var err error
+
+	if revision.Content, err = Read(revision.ContentKey, revision.Content); err != nil {
+		return err
+	}
+
+	revision.AuthorReplay, err = Read(revision.AuthorReplayKey, revision.AuthorReplay)
+
+	return err
+}
+
+// LoadLevel fills content and replay of the level from the blob store
+func LoadLevel(level *model.Level) error {
+	var err error
+
+	if level.Content, err = Read(level.ContentKey, level.Content); err != nil {
+		return err
+	}
+
+	level.AuthorReplay, err = Read(level.AuthorReplayKey, level.AuthorReplay)
+
+	return err
+}
+
+// LoadLevels fills content and replays of all levels from the blob store
+func LoadLevels(levels []model.Level) error {
+	for i := range levels {
+		if err := LoadLevel(&levels[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateTable moves inline content and replays of a table into the blob store in batches
+func migrateTable(db *gorm.DB, table string, report func(string)) error {
+	type row struct {
+		ID           string
+		Content      string
+		AuthorReplay string
+	}
+
+	moved := 0
+
+	for {
+		rows := []row{}
+
+		err := db.Table(table).
+			Select("id", "content", "author_replay").
+			Where("(content IS NOT NULL AND content != '') OR (author_replay IS NOT NULL AND author_replay != '')").
+			Limit(100).
+			Find(&rows).Error
+
+		if err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			report(fmt.Sprintf("%s: %d rows moved", table, moved))
+			return nil
+		}
+
+		for _, r := range rows {
+			contentKey, err := put(r.Content, contentType)
+
+			if err != nil {
+				return err
+			}
+
+			replayKey, err := put(r.AuthorReplay, replayContentType)
+
+			if err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{
+				"content":       "",
+				"author_replay": "",
+			}
+
+			if contentKey != "" {
+				updates["content_key"] = contentKey
+			}
+
+			if replayKey != "" {
+				updates["author_replay_key"] = replayKey
+			}
+
+			if err := db.Table(table).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+
+			moved++
+		}
+	}
+}
+
+// Migrate moves the content and replays still stored inline in levels and revisions into the blob store
+func Migrate(db *gorm.DB, report func(string)) error {
+	for _, table := range []string{(&model.Level{}).TableName(), (&model.LevelRevision{}).TableName()} {
+		if err := migrateTable(db, table, report); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
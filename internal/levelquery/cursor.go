@@ -0,0 +1,211 @@
+package levelquery
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrCursorInvalid = errors.New("cursor invalid")
+
+// Cursor points behind the last level of a page. It holds the sort value of that level
+// instead of an offset, so levels added meanwhile neither repeat nor skip entries.
+type Cursor struct {
+	Sort      SortType        `json:"s"`
+	Ascending bool            `json:"a,omitempty"`
+	Value     json.RawMessage `json:"v"`
+	ID        uuid.UUID       `json:"i"`
+}
+
+var cursorSecretOnce sync.Once
+var cursorSecret []byte
+
+// secret returns the configured cursor secret. Without one a random secret is used,
+// cursors are then only accepted by the instance that issued them.
+func secret() []byte {
+	cursorSecretOnce.Do(func() {
+		if config.C.Pagination.CursorSecret != "" {
+			cursorSecret = []byte(config.C.Pagination.CursorSecret)
+			return
+		}
+
+		cursorSecret = make([]byte, 32)
+
+		if _, err := rand.Read(cursorSecret); err != nil {
+			panic(err)
+		}
+	})
+
+	return cursorSecret
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, secret())
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Encode returns the opaque, signed form of the cursor handed to clients
+func (c *Cursor) Encode() (string, error) {
+	raw, err := json.Marshal(c)
+
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+
+	return payload + "." + sign(payload), nil
+}
+
+// DecodeCursor verifies and decodes a cursor created by Encode
+func DecodeCursor(encoded string) (*Cursor, error) {
+	payload, signature, found := strings.Cut(encoded, ".")
+
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return nil, ErrCursorInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	var cursor Cursor
+
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	return &cursor, nil
+}
+
+// NextCursor returns the cursor continuing a list sorted by sort after the level
+func NextCursor(db *gorm.DB, sort SortType, ascending bool, level *model.Level) (*Cursor, error) {
+	sort, key, err := lookupSort(sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value := key.value()
+
+	err = db.Model(&model.Level{}).
+		Select(key.expr).
+		Where("levels.id = ?", level.ID).
+		Row().
+		Scan(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{
+		Sort:      sort,
+		Ascending: ascending,
+		Value:     raw,
+		ID:        level.ID,
+	}, nil
+}
+
+// PageSize clamps the requested page size, 0 selects the default size
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return config.C.Pagination.DefaultPageSize
+	}
+
+	if limit > config.C.Pagination.MaxPageSize {
+		return config.C.Pagination.MaxPageSize
+	}
+
+	return limit
+}
+
+// Page is a validated request for a page of levels
+type Page struct {
+	Sort      SortType
+	Ascending bool
+	After     *Cursor
+	Size      int
+}
+
+// NewPage validates the sort order and the cursor of a page request
+func NewPage(sort SortType, ascending bool, cursor string, limit int) (Page, error) {
+	sort, _, err := lookupSort(sort)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{
+		Sort:      sort,
+		Ascending: ascending,
+		Size:      PageSize(limit),
+	}
+
+	if cursor != "" {
+		page.After, err = DecodeCursor(cursor)
+
+		if err != nil {
+			return Page{}, err
+		}
+
+		if page.After.Sort != sort || page.After.Ascending != ascending {
+			return Page{}, ErrCursorInvalid
+		}
+	}
+
+	return page, nil
+}
+
+// Find loads a page of the levels matched by the query. It returns the number of all matched
+// levels and the cursor of the next page, which is nil on the last page.
+func Find(tx *gorm.DB, page Page, levels *[]model.Level) (int64, *Cursor, error) {
+	var total int64
+
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	tx, err := Sort(tx, page.Sort, page.Ascending, page.After)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Limit(page.Size + 1).Find(levels).Error; err != nil {
+		return 0, nil, err
+	}
+
+	if len(*levels) <= page.Size {
+		return total, nil, nil
+	}
+
+	*levels = (*levels)[:page.Size]
+
+	next, err := NextCursor(tx.Session(&gorm.Session{NewDB: true}), page.Sort, page.Ascending, &(*levels)[page.Size-1])
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return total, next, nil
+}
@@ -0,0 +1,173 @@
+package levelquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/google/uuid"
+)
+
+// useSecret replaces the cursor secret for the duration of the test
+func useSecret(t *testing.T, secret string) {
+	previous := config.C.Pagination
+	t.Cleanup(func() {
+		config.C.Pagination = previous
+		cursorSecretOnce = sync.Once{}
+	})
+
+	config.C.Pagination.CursorSecret = secret
+	cursorSecretOnce = sync.Once{}
+}
+
+func flip(s string, i int) string {
+	b := []byte(s)
+
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+
+	return string(b)
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	useSecret(t, "test secret")
+
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"newest", Cursor{Sort: "newest", Value: json.RawMessage(`"2024-01-02T03:04:05Z"`), ID: uuid.New()}},
+		{"ascending", Cursor{Sort: "votes", Ascending: true, Value: json.RawMessage(`42`), ID: uuid.New()}},
+		{"null value", Cursor{Sort: "trending", Value: json.RawMessage(`null`), ID: uuid.Nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.cursor.Encode()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := DecodeCursor(encoded)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decoded.Sort != tt.cursor.Sort || decoded.Ascending != tt.cursor.Ascending ||
+				decoded.ID != tt.cursor.ID || string(decoded.Value) != string(tt.cursor.Value) {
+				t.Errorf("got %+v, want %+v", *decoded, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	useSecret(t, "test secret")
+
+	cursor := Cursor{Sort: "votes", Value: json.RawMessage(`42`), ID: uuid.New()}
+	encoded, err := cursor.Encode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, signature, _ := strings.Cut(encoded, ".")
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"votes","v":1000000,"i":"` + cursor.ID.String() + `"}`))
+	notJSON := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"without signature", payload},
+		{"empty signature", payload + "."},
+		{"changed payload", flip(payload, 0) + "." + signature},
+		{"changed signature", payload + "." + flip(signature, 0)},
+		{"forged payload", forged + "." + signature},
+		{"signature of another cursor", payload + "." + sign(forged)},
+		{"signed invalid base64", "!!!." + sign("!!!")},
+		{"signed invalid json", notJSON + "." + sign(notJSON)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.encoded); !errors.Is(err, ErrCursorInvalid) {
+				t.Errorf("got %v, want %v", err, ErrCursorInvalid)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsWrongSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder string
+		decoder string
+		valid   bool
+	}{
+		{"same secret", "first secret", "first secret", true},
+		{"other secret", "first secret", "second secret", false},
+		{"random secret", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSecret(t, tt.encoder)
+
+			encoded, err := (&Cursor{Sort: "newest", Value: json.RawMessage(`1`), ID: uuid.New()}).Encode()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// a new instance picks the secret again, an unset secret is random per instance
+			useSecret(t, tt.decoder)
+
+			_, err = DecodeCursor(encoded)
+
+			if tt.valid && err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+
+			if !tt.valid && !errors.Is(err, ErrCursorInvalid) {
+				t.Errorf("got %v, want %v", err, ErrCursorInvalid)
+			}
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	previous := config.C.Pagination
+	t.Cleanup(func() { config.C.Pagination = previous })
+
+	config.C.Pagination.DefaultPageSize = 20
+	config.C.Pagination.MaxPageSize = 100
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{-1, 20},
+		{0, 20},
+		{1, 1},
+		{100, 100},
+		{101, 100},
+	}
+
+	for _, tt := range tests {
+		if got := PageSize(tt.limit); got != tt.want {
+			t.Errorf("PageSize(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package levelquery
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SortType = string
+
+const SortPublished = SortType("published")
+const SortLikes = SortType("likes")
+const SortLikeRatio = SortType("like_ratio")
+const SortHot = SortType("hot")
+const SortTrending = SortType("trending")
+const SortPlays = SortType("plays")
+const SortClearRate = SortType("clear_rate")
+
+var ErrUnknownSort = errors.New("unknown sort")
+
+// Filter narrows down level lists, zero values don't filter
+type Filter struct {
+	AuthorID      *uuid.UUID
+	Result        model.ResultType
+	State         model.LevelState
+	PublishedFrom time.Time
+	PublishedTo   time.Time
+	DifficultyMin uint
+	DifficultyMax uint
+	Search        string
+}
+
+// sortKey is a sort order, the expression must never be null so it can be continued by a cursor
+type sortKey struct {
+	expr string
+	// value returns a pointer the cursor value is decoded into
+	value func() interface{}
+}
+
+var sortKeys = map[SortType]sortKey{
+	SortPublished: {
+		expr:  "levels.published",
+		value: func() interface{} { return new(time.Time) },
+	},
+	SortLikes: {
+		expr:  "levels.likes",
+		value: func() interface{} { return new(int64) },
+	},
+	SortLikeRatio: {
+		expr:  "COALESCE(levels.likes::float / NULLIF(levels.likes + levels.dislikes, 0), -1)",
+		value: func() interface{} { return new(float64) },
+	},
+	SortHot: {
+		expr:  "levels.hot_score",
+		value: func() interface{} { return new(float64) },
+	},
+	SortTrending: {
+		expr:  "levels.trending_score",
+		value: func() interface{} { return new(float64) },
+	},
+	SortPlays: {
+		expr:  "levels.plays",
+		value: func() interface{} { return new(int64) },
+	},
+	SortClearRate: {
+		expr:  "levels.clear_rate",
+		value: func() interface{} { return new(float64) },
+	},
+}
+
+// MigrateIndexes creates the trigram indexes the name and creator search relies on
+func MigrateIndexes(db *gorm.DB) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_levels_name_trgm ON levels USING gin (name gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_users_platform_name_trgm ON users USING gin (platform_name gin_trgm_ops)",
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// JoinValidation joins the validation of the level as v, optionally only levels validated with the result
+func JoinValidation(tx *gorm.DB, result model.ResultType) *gorm.DB {
+	if result == "" {
+		return tx.Joins("JOIN " + (&model.Validation{}).TableName() + " v ON v.id = levels.validation_id")
+	}
+
+	return tx.Joins("JOIN "+(&model.Validation{}).TableName()+" v ON v.id = levels.validation_id AND v.result = ?", result)
+}
+
+// Apply adds the filter conditions to a query on levels
+func Apply(tx *gorm.DB, filter Filter) *gorm.DB {
+	if filter.AuthorID != nil {
+		tx = tx.Where("levels.user_id = ?", *filter.AuthorID)
+	}
+
+	if filter.Result != "" {
+		tx = JoinValidation(tx, filter.Result)
+	}
+
+	if filter.State != "" {
+		tx = tx.Where("levels.state = ?", filter.State)
+	}
+
+	if !filter.PublishedFrom.IsZero() {
+		tx = tx.Where("levels.published >= ?", filter.PublishedFrom)
+	}
+
+	if !filter.PublishedTo.IsZero() {
+		tx = tx.Where("levels.published < ?", filter.PublishedTo)
+	}
+
+	if filter.DifficultyMin > 0 {
+		tx = tx.Where("levels.difficulty >= ?", filter.DifficultyMin)
+	}
+
+	if filter.DifficultyMax > 0 {
+		tx = tx.Where("levels.difficulty <= ?", filter.DifficultyMax)
+	}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+
+		tx = tx.Where(
+			"levels.name ILIKE ? OR levels.user_id IN (SELECT id FROM users WHERE platform_name ILIKE ?)",
+			pattern,
+			pattern,
+		)
+	}
+
+	return tx
+}
+
+func lookupSort(sort SortType) (SortType, sortKey, error) {
+	if sort == "" {
+		sort = SortPublished
+	}
+
+	key, ok := sortKeys[sort]
+
+	if !ok {
+		return sort, key, ErrUnknownSort
+	}
+
+	return sort, key, nil
+}
+
+// Sort orders a query on levels and continues after the cursor if there is one.
+// Ties are broken by id so pages stay stable while levels are added.
+func Sort(tx *gorm.DB, sort SortType, ascending bool, after *Cursor) (*gorm.DB, error) {
+	sort, key, err := lookupSort(sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	direction, comparison := " DESC", "<"
+
+	if ascending {
+		direction, comparison = " ASC", ">"
+	}
+
+	if after != nil {
+		if after.Sort != sort || after.Ascending != ascending {
+			return nil, ErrCursorInvalid
+		}
+
+		value := key.value()
+
+		if err := json.Unmarshal(after.Value, value); err != nil {
+			return nil, ErrCursorInvalid
+		}
+
+		tx = tx.Where("("+key.expr+", levels.id) "+comparison+" (?, ?)", value, after.ID)
+	}
+
+	return tx.Order(key.expr + direction).Order("levels.id" + direction), nil
+}
@@ -0,0 +1,204 @@
+package play
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/review"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLevelNotFound = errors.New("level not found")
+var ErrPlayNotFound = errors.New("play not found")
+var ErrPlayFinished = errors.New("play already finished")
+var ErrInvalidOutcome = errors.New("invalid play outcome")
+var ErrInvalidTime = errors.New("play time longer than the session")
+
+// timeGrace allows the play time measured by the game to exceed the time between
+// starting and finishing the session by this much, e.g. for requests sent late
+const timeGrace = time.Minute
+
+func IsValidOutcome(outcome model.PlayOutcome) bool {
+	return outcome == model.PlayOutcomeClear || outcome == model.PlayOutcomeFail || outcome == model.PlayOutcomeQuit
+}
+
+// lockLevel locks the public level row so the counters of concurrent sessions can't drift
+func lockLevel(tx *gorm.DB, levelID uuid.UUID) (*model.Level, error) {
+	var level model.Level
+
+	result := review.Public(tx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "user_id", "published_version").
+		Where("id = ?", levelID).
+		Limit(1).
+		Find(&level)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrLevelNotFound
+	}
+
+	return &level, nil
+}
+
+// Start opens a session of the user on the published version of the level and counts the play.
+// Plays of the creator aren't counted, like votes on own levels.
+func Start(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID) (*model.Play, error) {
+	if err := startLimiter().Allow(userID.String() + ":" + levelID.String()); err != nil {
+		return nil, err
+	}
+
+	var play *model.Play
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		level, err := lockLevel(tx, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		var earlierPlays int64
+
+		if err := tx.Model(&model.Play{}).Where("level_id = ? AND user_id = ?", levelID, userID).Count(&earlierPlays).Error; err != nil {
+			return err
+		}
+
+		play = &model.Play{
+			LevelID:   levelID,
+			UserID:    userID,
+			Version:   level.PublishedVersion,
+			StartedAt: time.Now(),
+		}
+
+		if err := tx.Create(play).Error; err != nil {
+			return err
+		}
+
+		if level.UserID == userID {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"plays":      gorm.Expr("plays + 1"),
+			"clear_rate": gorm.Expr("clears::float / (plays + 1)"),
+		}
+
+		if earlierPlays == 0 {
+			updates["unique_players"] = gorm.Expr("unique_players + 1")
+		}
+
+		return tx.Model(&model.Level{}).Where("id = ?", levelID).Updates(updates).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return play, nil
+}
+
+// Finish sets the outcome of a session of the user, a session can only be finished once
+func Finish(db *gorm.DB, userID uuid.UUID, playID uuid.UUID, outcome model.PlayOutcome, playTime time.Duration, deaths uint) (*model.Play, error) {
+	if !IsValidOutcome(outcome) {
+		return nil, ErrInvalidOutcome
+	}
+
+	var play model.Play
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", playID, userID).
+			Limit(1).
+			Find(&play)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrPlayNotFound
+		}
+
+		if play.Outcome != nil {
+			return ErrPlayFinished
+		}
+
+		now := time.Now()
+
+		if playTime > now.Sub(play.StartedAt)+timeGrace {
+			return ErrInvalidTime
+		}
+
+		play.Outcome = &outcome
+		play.TimeMs = uint(playTime.Milliseconds())
+		play.Deaths = deaths
+		play.FinishedAt = &now
+
+		err := tx.Model(&play).Updates(map[string]interface{}{
+			"outcome":     outcome,
+			"time_ms":     play.TimeMs,
+			"deaths":      deaths,
+			"finished_at": now,
+		}).Error
+
+		if err != nil {
+			return err
+		}
+
+		if outcome != model.PlayOutcomeClear {
+			return nil
+		}
+
+		return tx.Model(&model.Level{}).Where("id = ? AND user_id != ?", play.LevelID, userID).Updates(map[string]interface{}{
+			"clears":     gorm.Expr("clears + 1"),
+			"clear_rate": gorm.Expr("(clears + 1)::float / GREATEST(plays, 1)"),
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &play, nil
+}
+
+// recount updates the play counters of the levels matching the condition on l whose counters drifted
+func recount(tx *gorm.DB, condition string, args ...interface{}) error {
+	return tx.Exec(`
+		UPDATE levels SET plays = c.plays, unique_players = c.unique_players, clears = c.clears,
+			clear_rate = c.clears::float / GREATEST(c.plays, 1)
+		FROM (
+			SELECT l.id,
+				count(p.id) AS plays,
+				count(DISTINCT p.user_id) AS unique_players,
+				count(p.id) FILTER (WHERE p.outcome = ?) AS clears
+			FROM levels l LEFT JOIN plays p ON p.level_id = l.id AND p.user_id != l.user_id
+			WHERE `+condition+`
+			GROUP BY l.id
+		) c
+		WHERE c.id = levels.id
+			AND (levels.plays != c.plays OR levels.unique_players != c.unique_players OR levels.clears != c.clears)`,
+		append([]interface{}{model.PlayOutcomeClear}, args...)...,
+	).Error
+}
+
+// ReconcileCounters recounts the plays of all levels whose counters drifted,
+// e.g. after plays were dropped by an account deletion
+func ReconcileCounters(tx *gorm.DB) error {
+	return recount(tx, "TRUE")
+}
+
+// ReconcileLevels recounts the plays of the levels right away, e.g. after plays moved between accounts
+func ReconcileLevels(tx *gorm.DB, levelIDs []uuid.UUID) error {
+	if len(levelIDs) == 0 {
+		return nil
+	}
+
+	return recount(tx, "l.id IN ?", levelIDs)
+}
@@ -0,0 +1,21 @@
+package play
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/ratelimit"
+)
+
+var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+
+// UseRateLimitStore replaces the in-memory store of the play limits
+func UseRateLimitStore(store ratelimit.Store) {
+	rateLimitStore = store
+}
+
+// startLimiter limits how often a user starts sessions on the same level, so the play counters
+// and the hot score can't be inflated by restarting a level over and over
+func startLimiter() *ratelimit.Limiter {
+	return ratelimit.NewLimiter(rateLimitStore, "play-start", ratelimit.Per(config.C.RateLimit.PlayStartsPerLevelPerHour, time.Hour))
+}
@@ -0,0 +1,71 @@
+package ranking
+
+import (
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"gorm.io/gorm"
+)
+
+// hotScore ranks by net vote points plus points for players and clears, which sink with the age
+// of the level like on Hacker News
+const hotScore = `GREATEST(@likeWeight * l.likes - @dislikeWeight * l.dislikes
+		+ @playerWeight * l.unique_players + @clearWeight * l.clears, 0)
+	/ power(GREATEST(extract(epoch FROM now() - l.published)::float / 3600, 0) + 2, @gravity)`
+
+// recentVotes sums the votes of the trending window, each vote weighted down by its age
+const recentVotes = `SELECT level_id,
+		sum(power(0.5, extract(epoch FROM now() - updated_at)::float / 3600 / @halfLife)) FILTER (WHERE type = @like) AS likes,
+		sum(power(0.5, extract(epoch FROM now() - updated_at)::float / 3600 / @halfLife)) AS n
+	FROM votes
+	WHERE updated_at > @since
+	GROUP BY level_id`
+
+// recentPlays sums the plays and clears of the trending window by players other than the creator,
+// weighted down by the age of the start and the finish of the session
+const recentPlays = `SELECT p.level_id,
+		sum(power(0.5, extract(epoch FROM now() - p.started_at)::float / 3600 / @halfLife)) AS plays,
+		COALESCE(sum(power(0.5, extract(epoch FROM now() - p.finished_at)::float / 3600 / @halfLife))
+			FILTER (WHERE p.outcome = @clear AND p.finished_at > @since), 0) AS clears
+	FROM plays p JOIN levels pl ON pl.id = p.level_id AND pl.user_id != p.user_id
+	WHERE p.started_at > @since
+	GROUP BY p.level_id`
+
+// trendingScore is the lower bound of the Wilson score interval of the recent votes, levels need
+// many recent likes to rank high instead of a single one. Recent plays and clears add to it on a
+// logarithmic scale, so a busy level climbs without play count alone beating well liked levels.
+const trendingScore = `COALESCE((
+		r.likes / r.n + @z * @z / (2 * r.n)
+		- @z * sqrt((r.likes / r.n) * (1 - r.likes / r.n) / r.n + @z * @z / (4 * r.n * r.n))
+	) / (1 + @z * @z / r.n), 0)
+	+ @trendingPlayWeight * ln(1 + @playerWeight * COALESCE(a.plays, 0) + @clearWeight * COALESCE(a.clears, 0))`
+
+// Refresh recomputes the hot and trending scores of all levels
+func Refresh(tx *gorm.DB) error {
+	ranking := config.C.Ranking
+
+	return tx.Exec(`
+		UPDATE levels SET hot_score = s.hot, trending_score = s.trending
+		FROM (
+			SELECT l.id, `+hotScore+` AS hot, `+trendingScore+` AS trending
+			FROM levels l
+				LEFT JOIN (`+recentVotes+`) r ON r.level_id = l.id AND r.n > 0
+				LEFT JOIN (`+recentPlays+`) a ON a.level_id = l.id
+		) s
+		WHERE s.id = levels.id`,
+		map[string]interface{}{
+			"likeWeight":         ranking.LikeWeight,
+			"dislikeWeight":      ranking.DislikeWeight,
+			"playerWeight":       ranking.PlayerWeight,
+			"clearWeight":        ranking.ClearWeight,
+			"gravity":            ranking.HotGravity,
+			"halfLife":           ranking.TrendingHalfLifeHours,
+			"since":              time.Now().AddDate(0, 0, -ranking.TrendingWindowDays),
+			"like":               model.VoteLike,
+			"z":                  ranking.WilsonZ,
+			"clear":              model.PlayOutcomeClear,
+			"trendingPlayWeight": ranking.TrendingPlayWeight,
+		},
+	).Error
+}
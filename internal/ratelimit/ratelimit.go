@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit describes a token bucket refilled with Rate tokens per second holding at most Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Per allows n requests per period, all of them at once
+func Per(n int, period time.Duration) Limit {
+	return Limit{
+		Rate:  float64(n) / period.Seconds(),
+		Burst: n,
+	}
+}
+
+func (l Limit) Disabled() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Store keeps the buckets of all keys. The default in-memory store limits per replica,
+// a store backed by a shared database limits across all replicas.
+type Store interface {
+	// Take removes a token from the bucket of the key, if the bucket is empty it returns
+	// how long it takes until the next token is available
+	Take(key string, limit Limit) (bool, time.Duration, error)
+}
+
+// LimitedError is returned when a limit is exceeded
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// RetryAfterSeconds is the value of the Retry-After header, rounded up to whole seconds
+func (e *LimitedError) RetryAfterSeconds() string {
+	return strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+func NewLimiter(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{
+		store: store,
+		name:  name,
+		limit: limit,
+	}
+}
+
+// Allow takes a token for the key and returns a *LimitedError if there is none left
+func (l *Limiter) Allow(key string) error {
+	if l == nil || l.limit.Disabled() {
+		return nil
+	}
+
+	ok, retryAfter, err := l.store.Take(l.name+":"+key, l.limit)
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &LimitedError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// Abort answers with 429 and a Retry-After header
+func Abort(context *gin.Context, err *LimitedError) {
+	context.Header("Retry-After", err.RetryAfterSeconds())
+	context.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+}
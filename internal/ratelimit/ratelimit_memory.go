@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// buckets are swept every sweepInterval takes, full buckets are forgotten
+const sweepInterval = 1024
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore is an in-process token bucket store
+type MemoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	limits  map[string]Limit
+	takes   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		limits:  map[string]Limit{},
+		now:     time.Now,
+	}
+}
+
+func (b *bucket) refill(now time.Time, limit Limit) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		b.refill(now, s.limits[key])
+
+		if b.tokens >= float64(s.limits[key].Burst) {
+			delete(s.buckets, key)
+			delete(s.limits, key)
+		}
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+
+	s.takes++
+
+	if s.takes%sweepInterval == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+
+	if !ok {
+		b = &bucket{
+			tokens:  float64(limit.Burst),
+			updated: now,
+		}
+
+		s.buckets[key] = b
+	}
+
+	s.limits[key] = limit
+
+	b.refill(now, limit)
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0, nil
+	}
+
+	retryAfter := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+
+	return false, retryAfter, nil
+}
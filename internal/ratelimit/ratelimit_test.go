@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clock is advanced by the tests instead of sleeping
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = c.Now
+
+	return store, c
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// a step takes a token after advancing the clock
+	type step struct {
+		advance    time.Duration
+		ok         bool
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{"burst then exhausted", Per(3, time.Minute), []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, 20 * time.Second},
+		}},
+		{"retry after shrinks while waiting", Per(1, time.Minute), []step{
+			{0, true, 0},
+			{0, false, time.Minute},
+			{45 * time.Second, false, 15 * time.Second},
+		}},
+		{"refills one token", Per(2, time.Minute), []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, 30 * time.Second},
+			{30 * time.Second, true, 0},
+			{0, false, 30 * time.Second},
+		}},
+		{"refill is capped at burst", Per(2, time.Minute), []step{
+			{0, true, 0},
+			{0, true, 0},
+			{time.Hour, true, 0},
+			{0, true, 0},
+			{0, false, 30 * time.Second},
+		}},
+		{"rejected takes do not drain", Limit{Rate: 1, Burst: 1}, []step{
+			{0, true, 0},
+			{0, false, time.Second},
+			{0, false, time.Second},
+			{time.Second, true, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, c := newTestStore()
+
+			for i, s := range tt.steps {
+				c.now = c.now.Add(s.advance)
+
+				ok, retryAfter, err := store.Take("key", tt.limit)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if ok != s.ok || retryAfter != s.retryAfter {
+					t.Errorf("step %d: got %v, %s, want %v, %s", i, ok, retryAfter, s.ok, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreSeparate(t *testing.T) {
+	store, _ := newTestStore()
+	limit := Per(1, time.Minute)
+
+	if ok, _, _ := store.Take("a", limit); !ok {
+		t.Fatal("first take of a rejected")
+	}
+
+	if ok, _, _ := store.Take("a", limit); ok {
+		t.Error("second take of a allowed")
+	}
+
+	if ok, _, _ := store.Take("b", limit); !ok {
+		t.Error("first take of b rejected")
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      Limit
+		takes      int
+		retryAfter time.Duration
+	}{
+		{"within limit", Per(2, time.Minute), 2, 0},
+		{"exceeded", Per(2, time.Minute), 3, 30 * time.Second},
+		{"disabled rate", Limit{Rate: 0, Burst: 1}, 10, 0},
+		{"disabled burst", Limit{Rate: 1, Burst: 0}, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			limiter := NewLimiter(store, "test", tt.limit)
+
+			var err error
+
+			for i := 0; i < tt.takes; i++ {
+				err = limiter.Allow("key")
+			}
+
+			var limited *LimitedError
+
+			if tt.retryAfter == 0 {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+
+				return
+			}
+
+			if !errors.As(err, &limited) {
+				t.Fatalf("got %v, want a *LimitedError", err)
+			}
+
+			if limited.RetryAfter != tt.retryAfter {
+				t.Errorf("got retry after %s, want %s", limited.RetryAfter, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestNilLimiterAllows(t *testing.T) {
+	var limiter *Limiter
+
+	if err := limiter.Allow("key"); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{0, "0"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{time.Second + time.Nanosecond, "2"},
+		{20 * time.Second, "20"},
+		{59*time.Second + 500*time.Millisecond, "60"},
+	}
+
+	for _, tt := range tests {
+		err := &LimitedError{RetryAfter: tt.retryAfter}
+
+		if got := err.RetryAfterSeconds(); got != tt.want {
+			t.Errorf("RetryAfterSeconds() for %s = %s, want %s", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+
+	Abort(context, &LimitedError{RetryAfter: 1500 * time.Millisecond})
+
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want %d", recorder.Code, http.StatusTooManyRequests)
+	}
+
+	if got := recorder.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("got Retry-After %q, want %q", got, "2")
+	}
+
+	if !context.IsAborted() {
+		t.Error("context not aborted")
+	}
+}
@@ -0,0 +1,363 @@
+package review
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/audit"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/internal/revision"
+	"github.com/Lyretto/spooky-bodies-golang/internal/thumbnail"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLevelNotFound = errors.New("level not found")
+var ErrInvalidTransition = errors.New("level can't change into this state")
+var ErrVersionChanged = errors.New("level version changed during validation")
+
+// MigrateStates derives the state of levels created before levels had one. Levels validated as ok
+// are published, other validated levels rejected and unvalidated levels wait for review.
+func MigrateStates(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE levels SET
+			state = CASE WHEN v.result = @ok THEN @published WHEN v.id IS NOT NULL THEN @rejected ELSE @pending END,
+			published_version = CASE WHEN v.result = @ok THEN levels.version ELSE 0 END
+		FROM levels l LEFT JOIN validations v ON v.id = l.validation_id
+		WHERE l.id = levels.id AND (levels.state IS NULL OR levels.state = '')`,
+		map[string]interface{}{
+			"ok":        model.ResultOk,
+			"published": model.LevelStatePublished,
+			"rejected":  model.LevelStateRejected,
+			"pending":   model.LevelStatePending,
+		},
+	).Error
+}
+
+// IsPublic reports whether players other than the creator can see the level
+func IsPublic(level *model.Level) bool {
+	return level.PublishedVersion > 0 && level.State != model.LevelStateHidden
+}
+
+// Public restricts a query on levels to the levels players can see
+func Public(tx *gorm.DB) *gorm.DB {
+	return tx.Where("levels.published_version > 0 AND levels.state != ?", model.LevelStateHidden)
+}
+
+func lockLevel(tx *gorm.DB, levelID uuid.UUID) (*model.Level, error) {
+	var level model.Level
+
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", levelID).Limit(1).Find(&level)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrLevelNotFound
+	}
+
+	return &level, nil
+}
+
+// Create stores a new level as a draft with its first revision
+func Create(db *gorm.DB, level *model.Level) error {
+	level.Version = 1
+	level.State = model.LevelStateDraft
+
+	if err := levelcontent.ExternaliseLevel(level); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(level).Error; err != nil {
+			return err
+		}
+
+		return revision.Record(tx, level)
+	})
+}
+
+// Revise stores the changes as the next revision of the level and turns it back into a draft.
+// A published revision stays live until the new revision is published.
+func Revise(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID, changes model.LevelRevision) (*model.Level, error) {
+	var level *model.Level
+
+	if err := levelcontent.Externalise(&changes); err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		level, err = lockLevel(tx, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if level.UserID != userID {
+			return ErrLevelNotFound
+		}
+
+		if level.State == model.LevelStateHidden {
+			return ErrInvalidTransition
+		}
+
+		level.Version += 1
+		level.State = model.LevelStateDraft
+
+		updates := map[string]interface{}{
+			"version": level.Version,
+			"state":   level.State,
+		}
+
+		// without a published revision the level shows its latest revision
+		if level.PublishedVersion == 0 {
+			level.Name = changes.Name
+			level.Content = changes.Content
+			level.AuthorReplay = changes.AuthorReplay
+			level.ContentKey = changes.ContentKey
+			level.AuthorReplayKey = changes.AuthorReplayKey
+			level.Difficulty = changes.Difficulty
+			level.Complexity = changes.Complexity
+			level.ValidationId = nil
+
+			updates["name"] = level.Name
+			updates["content"] = level.Content
+			updates["author_replay"] = level.AuthorReplay
+			updates["content_key"] = level.ContentKey
+			updates["author_replay_key"] = level.AuthorReplayKey
+			updates["difficulty"] = level.Difficulty
+			updates["complexity"] = level.Complexity
+			updates["validation_id"] = nil
+		}
+
+		if err := tx.Model(level).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		changes.LevelID = level.ID
+		changes.Version = level.Version
+		changes.ValidationID = nil
+
+		return tx.Create(&changes).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// Submit puts the latest revision of a draft into the review queue. Revisions above the
+// configured complexity are rejected right away, the resulting state is returned.
+func Submit(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID) (model.LevelState, error) {
+	var state model.LevelState
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		level, err := lockLevel(tx, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if level.UserID != userID {
+			return ErrLevelNotFound
+		}
+
+		if level.State != model.LevelStateDraft {
+			return ErrInvalidTransition
+		}
+
+		latest, err := revision.Find(tx, level.ID, level.Version)
+
+		if err != nil {
+			return err
+		}
+
+		maxComplexity := config.C.LevelFormat.MaxComplexity
+
+		if maxComplexity > 0 && latest.Complexity > uint(maxComplexity) {
+			// the server rejects on its own, the validation has no validator
+			_, err := applyOutcome(tx, level, Outcome{Result: model.ResultContentTooComplex})
+			state = model.LevelStateRejected
+
+			return err
+		}
+
+		state = model.LevelStatePending
+
+		return tx.Model(level).Update("state", state).Error
+	})
+
+	return state, err
+}
+
+// Outcome is the result of validating the pending revision of a level.
+// Version is the validated version, 0 validates the latest one.
+// Content replaces the content of the revision if set, Complexity is the one of that content.
+// Thumbnails rendered by the validator replace the thumbnails of the level if the result is ok,
+// otherwise thumbnails the creator uploaded since the last publication go live.
+type Outcome struct {
+	Version     uint
+	Result      model.ResultType
+	ValidatorID uuid.UUID
+	Content     string
+	Complexity  uint
+	AuthorScore int
+	Thumbnails  []model.Thumbnail
+}
+
+// Validate records the outcome for the pending revision. An ok result publishes the revision,
+// any other rejects it while a previously published revision stays live.
+func Validate(db *gorm.DB, levelID uuid.UUID, outcome Outcome) (*model.Validation, error) {
+	var validation *model.Validation
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		level, err := lockLevel(tx, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if level.State != model.LevelStatePending {
+			return ErrInvalidTransition
+		}
+
+		if outcome.Version != 0 && outcome.Version != level.Version {
+			return ErrVersionChanged
+		}
+
+		validation, err = applyOutcome(tx, level, outcome)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return validation, nil
+}
+
+func applyOutcome(tx *gorm.DB, level *model.Level, outcome Outcome) (*model.Validation, error) {
+	validation := model.Validation{
+		LevelVersion: level.Version,
+		Result:       outcome.Result,
+		ValidatorID:  outcome.ValidatorID,
+	}
+
+	if err := tx.Create(&validation).Error; err != nil {
+		return nil, err
+	}
+
+	// earlier validations stay, the revisions they belong to still refer to them
+	if err := revision.SetValidation(tx, level.ID, level.Version, validation.ID, outcome.Content, outcome.Complexity); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"validation_lock":     time.Time{},
+		"validation_agent_id": nil,
+	}
+
+	if outcome.Result == model.ResultOk {
+		published, err := revision.Find(tx, level.ID, level.Version)
+
+		if err != nil {
+			return nil, err
+		}
+
+		updates["state"] = model.LevelStatePublished
+		updates["published_version"] = level.Version
+		updates["published"] = time.Now()
+		updates["name"] = published.Name
+		updates["content"] = published.Content
+		updates["author_replay"] = published.AuthorReplay
+		updates["content_key"] = published.ContentKey
+		updates["author_replay_key"] = published.AuthorReplayKey
+		updates["difficulty"] = published.Difficulty
+		updates["complexity"] = published.Complexity
+		updates["validation_id"] = validation.ID
+		updates["author_score"] = outcome.AuthorScore
+
+		if err := assignShareCode(tx, level); err != nil {
+			return nil, err
+		}
+
+		if len(outcome.Thumbnails) > 0 {
+			err = thumbnail.Replace(tx, level.ID, outcome.Thumbnails)
+		} else {
+			err = thumbnail.Publish(tx, level.ID)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		updates["state"] = model.LevelStateRejected
+
+		if level.PublishedVersion == 0 {
+			updates["validation_id"] = validation.ID
+		}
+	}
+
+	if err := tx.Model(level).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	return &validation, nil
+}
+
+// SetHidden hides a level from players or shows it again, unhiding restores the state the level
+// had when it was hidden. Levels hidden before that state was kept become published or drafts.
+func SetHidden(db *gorm.DB, levelID uuid.UUID, hidden bool, actorID uuid.UUID, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		level, err := lockLevel(tx, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if (level.State == model.LevelStateHidden) == hidden {
+			return ErrInvalidTransition
+		}
+
+		updates := map[string]interface{}{
+			"state":             model.LevelStateHidden,
+			"hidden_from_state": level.State,
+		}
+
+		action := model.AuditLevelHidden
+
+		if !hidden {
+			action = model.AuditLevelUnhidden
+
+			state := level.HiddenFromState
+
+			if state == "" {
+				state = model.LevelStateDraft
+
+				if level.PublishedVersion > 0 {
+					state = model.LevelStatePublished
+				}
+			}
+
+			updates["state"] = state
+			updates["hidden_from_state"] = ""
+		}
+
+		if err := tx.Model(level).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return audit.Record(tx, &actorID, model.AuditSourceAPI, action, level.ID, map[string]interface{}{
+			"reason": reason,
+		})
+	})
+}
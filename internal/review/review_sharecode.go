@@ -0,0 +1,100 @@
+package review
+
+import (
+	"errors"
+
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/sharecode"
+	"gorm.io/gorm"
+)
+
+// maxShareCodeAttempts bounds the retries on collisions, with 31^8 codes even one retry is rare
+const maxShareCodeAttempts = 10
+
+var ErrShareCodeExhausted = errors.New("no free share code found")
+
+// assignShareCode gives the level a share code unless it has one. Codes stay with the level
+// for good, links shared for an older version keep working.
+func assignShareCode(tx *gorm.DB, level *model.Level) error {
+	if level.ShareCode != nil {
+		return nil
+	}
+
+	for i := 0; i < maxShareCodeAttempts; i++ {
+		code, err := sharecode.Generate()
+
+		if err != nil {
+			return err
+		}
+
+		var taken int64
+
+		if err := tx.Model(&model.Level{}).Where("share_code = ?", code).Count(&taken).Error; err != nil {
+			return err
+		}
+
+		if taken > 0 {
+			continue
+		}
+
+		if err := tx.Model(level).Update("share_code", code).Error; err != nil {
+			return err
+		}
+
+		level.ShareCode = &code
+
+		return nil
+	}
+
+	return ErrShareCodeExhausted
+}
+
+// MigrateShareCodes gives share codes to the levels published before there were any
+func MigrateShareCodes(db *gorm.DB) error {
+	levels := []model.Level{}
+
+	err := db.Select("id", "share_code").
+		Where("published_version > 0 AND share_code IS NULL").
+		Find(&levels).Error
+
+	if err != nil {
+		return err
+	}
+
+	for i := range levels {
+		if err := assignShareCode(db, &levels[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindByShareCode looks up a level by a code typed by a player. A code with a mistyped character
+// is resolved if exactly one of its possible corrections belongs to a level found by tx.
+func FindByShareCode(tx *gorm.DB, input string) (*model.Level, error) {
+	codes := []string{}
+
+	code, err := sharecode.Normalize(input)
+
+	switch {
+	case err == nil:
+		codes = append(codes, code)
+	case errors.Is(err, sharecode.ErrChecksum):
+		codes = sharecode.Corrections(input)
+	default:
+		return nil, err
+	}
+
+	levels := []model.Level{}
+
+	if err := tx.Where("levels.share_code IN ?", codes).Limit(2).Find(&levels).Error; err != nil {
+		return nil, err
+	}
+
+	if len(levels) != 1 {
+		return nil, ErrLevelNotFound
+	}
+
+	return &levels[0], nil
+}
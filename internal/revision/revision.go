@@ -0,0 +1,105 @@
+package revision
+
+import (
+	"errors"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/levelcontent"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// MigrateRevisions records the current state of levels created before revisions were kept
+func MigrateRevisions(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO level_revisions (level_id, version, name, content, author_replay, difficulty, validation_id, created_at)
+		SELECT id, version, name, content, author_replay, difficulty, validation_id, now() FROM levels
+		ON CONFLICT DO NOTHING
+	`).Error
+}
+
+// Record stores the current state of the level as the revision of its version
+func Record(tx *gorm.DB, level *model.Level) error {
+	return tx.Create(&model.LevelRevision{
+		LevelID:         level.ID,
+		Version:         level.Version,
+		Name:            level.Name,
+		Content:         level.Content,
+		AuthorReplay:    level.AuthorReplay,
+		ContentKey:      level.ContentKey,
+		AuthorReplayKey: level.AuthorReplayKey,
+		Difficulty:      level.Difficulty,
+		Complexity:      level.Complexity,
+		ValidationID:    level.ValidationId,
+	}).Error
+}
+
+// SetValidation attaches the validation to the revision it was made for, content replaces
+// the content of the revision if the validator changed it, complexity is the one of that content
+func SetValidation(tx *gorm.DB, levelID uuid.UUID, version uint, validationID uuid.UUID, content string, complexity uint) error {
+	updates := map[string]interface{}{
+		"validation_id": validationID,
+	}
+
+	if content != "" {
+		contentKey, err := levelcontent.ContentKey(content)
+
+		if err != nil {
+			return err
+		}
+
+		updates["content"] = ""
+		updates["content_key"] = contentKey
+		updates["complexity"] = complexity
+	}
+
+	result := tx.Model(&model.LevelRevision{}).
+		Where("level_id = ? AND version = ?", levelID, version).
+		Updates(updates)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrRevisionNotFound
+	}
+
+	return nil
+}
+
+// List returns all revisions of the level without their content, newest first
+func List(db *gorm.DB, levelID uuid.UUID) ([]model.LevelRevision, error) {
+	revisions := []model.LevelRevision{}
+
+	err := db.
+		Preload("Validation").
+		Omit("content", "author_replay", "content_key", "author_replay_key").
+		Where("level_id = ?", levelID).
+		Order("version DESC").
+		Find(&revisions).Error
+
+	return revisions, err
+}
+
+func Find(db *gorm.DB, levelID uuid.UUID, version uint) (*model.LevelRevision, error) {
+	var revision model.LevelRevision
+
+	result := db.
+		Preload("Validation").
+		Where("level_id = ? AND version = ?", levelID, version).
+		Limit(1).
+		Find(&revision)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrRevisionNotFound
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,354 @@
+package thumbnail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/blob"
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+var ErrUnsupportedFormat = errors.New("thumbnail must be a png, jpeg or webp image")
+var ErrInvalidDimensions = errors.New("thumbnail dimensions out of bounds")
+var ErrTooLarge = errors.New("thumbnail too large")
+
+type size struct {
+	name   model.ThumbnailSize
+	width  int
+	height int
+}
+
+// sizes are the renditions generated for every upload, all 16:9 like the level browser
+var sizes = []size{
+	{model.ThumbnailSmall, 320, 180},
+	{model.ThumbnailLarge, 1280, 720},
+}
+
+var formats = map[string]bool{
+	"png":  true,
+	"jpeg": true,
+	"webp": true,
+}
+
+const jpegQuality = 85
+
+// decode checks format and dimensions from the header before decoding, so huge images are
+// rejected without allocating them
+func decode(data []byte) (image.Image, error) {
+	limits := config.C.Thumbnails
+
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	header, format, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil || !formats[format] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if header.Width < limits.MinWidth || header.Height < limits.MinHeight ||
+		header.Width > limits.MaxWidth || header.Height > limits.MaxHeight {
+		return nil, fmt.Errorf("%w: %dx%d", ErrInvalidDimensions, header.Width, header.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return img, nil
+}
+
+// cover scales the image to fill width and height, cropping the overflow evenly on both sides
+func cover(src image.Image, width int, height int) image.Image {
+	bounds := src.Bounds()
+	crop := bounds
+
+	if bounds.Dx()*height > bounds.Dy()*width {
+		cropWidth := bounds.Dy() * width / height
+		crop.Min.X += (bounds.Dx() - cropWidth) / 2
+		crop.Max.X = crop.Min.X + cropWidth
+	} else {
+		cropHeight := bounds.Dx() * height / width
+		crop.Min.Y += (bounds.Dy() - cropHeight) / 2
+		crop.Max.Y = crop.Min.Y + cropHeight
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	return dst
+}
+
+// Render decodes an uploaded image and generates all standard sizes
+func Render(levelID uuid.UUID, data []byte) ([]model.Thumbnail, error) {
+	src, err := decode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnails := make([]model.Thumbnail, 0, len(sizes))
+
+	for _, s := range sizes {
+		var buf bytes.Buffer
+
+		if err := jpeg.Encode(&buf, cover(src, s.width, s.height), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(buf.Bytes())
+
+		thumbnails = append(thumbnails, model.Thumbnail{
+			LevelID:     levelID,
+			Size:        s.name,
+			Width:       s.width,
+			Height:      s.height,
+			ContentType: "image/jpeg",
+			Data:        buf.Bytes(),
+			Hash:        hex.EncodeToString(sum[:]),
+		})
+	}
+
+	return thumbnails, nil
+}
+
+// MigrateIndex drops the unique index on level and size, which left no room for pending thumbnails
+func MigrateIndex(db *gorm.DB) error {
+	return db.Exec("DROP INDEX IF EXISTS idx_thumbnails_level_size").Error
+}
+
+// Store renders the uploaded image and replaces the thumbnails of the level, pending ones are
+// kept until the next revision of the level is validated
+func Store(db *gorm.DB, levelID uuid.UUID, data []byte, pending bool) ([]model.Thumbnail, error) {
+	thumbnails, err := Render(levelID, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return replace(tx, levelID, thumbnails, pending)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return thumbnails, nil
+}
+
+// Replace moves rendered thumbnails to the blob store and makes them the live thumbnails of the level,
+// thumbnails still pending are dropped
+func Replace(tx *gorm.DB, levelID uuid.UUID, thumbnails []model.Thumbnail) error {
+	if err := tx.Where("level_id = ? AND pending", levelID).Delete(&model.Thumbnail{}).Error; err != nil {
+		return err
+	}
+
+	return replace(tx, levelID, thumbnails, false)
+}
+
+func replace(tx *gorm.DB, levelID uuid.UUID, thumbnails []model.Thumbnail, pending bool) error {
+	var err error
+
+	for i := range thumbnails {
+		if thumbnails[i].BlobKey, err = blob.Put(thumbnails[i].Data, thumbnails[i].ContentType); err != nil {
+			return err
+		}
+
+		thumbnails[i].Data = nil
+		thumbnails[i].Pending = pending
+	}
+
+	if err := tx.Where("level_id = ? AND pending = ?", levelID, pending).Delete(&model.Thumbnail{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&thumbnails).Error
+}
+
+// Publish makes the pending thumbnails of the level live, if it has any
+func Publish(tx *gorm.DB, levelID uuid.UUID) error {
+	var pending int64
+
+	if err := tx.Model(&model.Thumbnail{}).Where("level_id = ? AND pending", levelID).Count(&pending).Error; err != nil {
+		return err
+	}
+
+	if pending == 0 {
+		return nil
+	}
+
+	if err := tx.Where("level_id = ? AND NOT pending", levelID).Delete(&model.Thumbnail{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&model.Thumbnail{}).Where("level_id = ? AND pending", levelID).Update("pending", false).Error
+}
+
+// Find loads a thumbnail including its image data
+func Find(db *gorm.DB, id uuid.UUID) (*model.Thumbnail, error) {
+	var thumbnail model.Thumbnail
+
+	result := db.Where("id = ?", id).Limit(1).Find(&thumbnail)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if thumbnail.BlobKey != "" {
+		data, err := blob.Get(thumbnail.BlobKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		thumbnail.Data = data
+	}
+
+	return &thumbnail, nil
+}
+
+// URL is where clients fetch the thumbnail
+func URL(thumbnail *model.Thumbnail) string {
+	return config.C.Thumbnails.BaseURL + "/thumbnails/" + thumbnail.ID.String()
+}
+
+// URLs maps the sizes of the thumbnails to their urls
+func URLs(thumbnails []model.Thumbnail) map[model.ThumbnailSize]string {
+	urls := map[model.ThumbnailSize]string{}
+
+	for i := range thumbnails {
+		urls[thumbnails[i].Size] = URL(&thumbnails[i])
+	}
+
+	return urls
+}
+
+// FillURLs sets the thumbnail urls of the levels, the image data isn't loaded
+func FillURLs(db *gorm.DB, levels []model.Level) error {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	levelIDs := make([]uuid.UUID, len(levels))
+
+	for i, level := range levels {
+		levelIDs[i] = level.ID
+	}
+
+	thumbnails := []model.Thumbnail{}
+
+	err := db.Select("id", "level_id", "size").Where("level_id IN ? AND NOT pending", levelIDs).Find(&thumbnails).Error
+
+	if err != nil {
+		return err
+	}
+
+	byLevel := map[uuid.UUID][]model.Thumbnail{}
+
+	for _, t := range thumbnails {
+		byLevel[t.LevelID] = append(byLevel[t.LevelID], t)
+	}
+
+	for i := range levels {
+		levels[i].Thumbnails = URLs(byLevel[levels[i].ID])
+	}
+
+	return nil
+}
+
+// MigrateInline moves thumbnails stored in the levels row into the thumbnails table.
+// Images that can't be decoded are dropped, the game regenerates them on the next validation.
+func MigrateInline(db *gorm.DB) error {
+	for {
+		levels := []model.Level{}
+
+		err := db.Select("id", "thumbnail").
+			Where("thumbnail IS NOT NULL").
+			Limit(50).
+			Find(&levels).Error
+
+		if err != nil {
+			return err
+		}
+
+		if len(levels) == 0 {
+			return nil
+		}
+
+		for _, level := range levels {
+			if len(level.Thumbnail) > 0 {
+				if _, err := Store(db, level.ID, level.Thumbnail, false); err != nil && !isImageError(err) {
+					return err
+				}
+			}
+
+			if err := db.Model(&level).Update("thumbnail", nil).Error; err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// MigrateBlobs moves the image data still stored in the thumbnails table into the blob store
+func MigrateBlobs(db *gorm.DB, report func(string)) error {
+	moved := 0
+
+	for {
+		thumbnails := []model.Thumbnail{}
+
+		err := db.Where("(blob_key IS NULL OR blob_key = '') AND data IS NOT NULL").
+			Limit(50).
+			Find(&thumbnails).Error
+
+		if err != nil {
+			return err
+		}
+
+		if len(thumbnails) == 0 {
+			report(fmt.Sprintf("%s: %d rows moved", (&model.Thumbnail{}).TableName(), moved))
+			return nil
+		}
+
+		for _, t := range thumbnails {
+			key, err := blob.Put(t.Data, t.ContentType)
+
+			if err != nil {
+				return err
+			}
+
+			err = db.Model(&t).Updates(map[string]interface{}{
+				"blob_key": key,
+				"data":     nil,
+			}).Error
+
+			if err != nil {
+				return err
+			}
+
+			moved++
+		}
+	}
+}
+
+func isImageError(err error) bool {
+	return errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrInvalidDimensions) || errors.Is(err, ErrTooLarge)
+}
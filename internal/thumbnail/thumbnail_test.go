@@ -0,0 +1,178 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/config"
+)
+
+func useLimits(t *testing.T, limits config.Thumbnails) {
+	previous := config.C.Thumbnails
+	t.Cleanup(func() { config.C.Thumbnails = previous })
+
+	config.C.Thumbnails = limits
+}
+
+func encodePNG(t *testing.T, width int, height int) []byte {
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// pngHeader returns only the signature and header chunk of a png claiming the dimensions,
+// decoding the pixels of it fails
+func pngHeader(width uint32, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // truecolor with alpha
+
+	chunk := append([]byte("IHDR"), ihdr...)
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	useLimits(t, config.Thumbnails{
+		MaxBytes:  64 * 1024,
+		MinWidth:  16,
+		MinHeight: 9,
+		MaxWidth:  1920,
+		MaxHeight: 1080,
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"valid", encodePNG(t, 160, 90), nil},
+		{"smallest", encodePNG(t, 16, 9), nil},
+		{"largest", encodePNG(t, 1920, 1080), nil},
+		{"too narrow", encodePNG(t, 15, 90), ErrInvalidDimensions},
+		{"too low", encodePNG(t, 160, 8), ErrInvalidDimensions},
+		{"too wide", encodePNG(t, 1921, 90), ErrInvalidDimensions},
+		{"too high", encodePNG(t, 160, 1081), ErrInvalidDimensions},
+		// the header alone claims gigabytes of pixels, it must be rejected before decoding
+		{"bomb", pngHeader(100000, 100000), ErrInvalidDimensions},
+		{"truncated", pngHeader(160, 90), ErrUnsupportedFormat},
+		{"too many bytes", make([]byte, 64*1024+1), ErrTooLarge},
+		{"empty", nil, ErrUnsupportedFormat},
+		{"unsupported format", []byte("GIF89a\x10\x00\x09\x00\x00\x00\x00;"), ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decode(tt.data)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if err == nil && img == nil {
+				t.Error("got no image")
+			}
+		})
+	}
+}
+
+func TestDecodeWithoutByteLimit(t *testing.T) {
+	useLimits(t, config.Thumbnails{MaxWidth: 1920, MaxHeight: 1080})
+
+	if _, err := decode(encodePNG(t, 1920, 1080)); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+)
+
+// stripes returns an image split into three equal red, green and blue stripes,
+// side by side if vertical, else on top of each other
+func stripes(width int, height int, vertical bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	colors := []color.RGBA{red, green, blue}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if vertical {
+				img.Set(x, y, colors[x*3/width])
+			} else {
+				img.Set(x, y, colors[y*3/height])
+			}
+		}
+	}
+
+	return img
+}
+
+func TestCover(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    image.Image
+		width  int
+		height int
+	}{
+		// only the middle stripe is left after cropping the overflow on both sides
+		{"wide source", stripes(900, 100, true), 100, 100},
+		{"tall source", stripes(160, 270, false), 160, 90},
+		{"wide source scaled down", stripes(1920, 360, true), 320, 180},
+		{"tall source scaled up", stripes(30, 90, false), 320, 320},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := cover(tt.src, tt.width, tt.height)
+
+			if got := dst.Bounds(); got != image.Rect(0, 0, tt.width, tt.height) {
+				t.Fatalf("got bounds %v, want %v", got, image.Rect(0, 0, tt.width, tt.height))
+			}
+
+			points := []image.Point{
+				{0, 0},
+				{tt.width - 1, 0},
+				{tt.width / 2, tt.height / 2},
+				{0, tt.height - 1},
+				{tt.width - 1, tt.height - 1},
+			}
+
+			for _, p := range points {
+				if got := color.RGBAModel.Convert(dst.At(p.X, p.Y)); got != green {
+					t.Errorf("got %v at %v, want %v", got, p, green)
+				}
+			}
+		})
+	}
+}
+
+func TestCoverKeepsMatchingAspectRatio(t *testing.T) {
+	dst := cover(stripes(320, 180, true), 160, 90)
+
+	left := color.RGBAModel.Convert(dst.At(0, 45))
+	right := color.RGBAModel.Convert(dst.At(159, 45))
+
+	if left != red || right != blue {
+		t.Errorf("got %v and %v at the edges, want %v and %v", left, right, red, blue)
+	}
+}
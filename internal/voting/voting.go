@@ -0,0 +1,206 @@
+package voting
+
+import (
+	"errors"
+
+	"github.com/Lyretto/spooky-bodies-golang/internal/review"
+	"github.com/Lyretto/spooky-bodies-golang/pkg/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidVoteType = errors.New("invalid vote type")
+var ErrLevelNotFound = errors.New("level not found")
+var ErrNoVote = errors.New("no vote on this level")
+
+func IsValidVoteType(voteType model.VoteType) bool {
+	return voteType == model.VoteLike || voteType == model.VoteDislike
+}
+
+func counterColumn(voteType model.VoteType) string {
+	if voteType == model.VoteLike {
+		return "likes"
+	}
+
+	return "dislikes"
+}
+
+// lockLevel locks the public level row, which serialises all vote changes on the level so the counters can't drift
+func lockLevel(tx *gorm.DB, userID uuid.UUID, levelID uuid.UUID) error {
+	var level model.Level
+
+	result := review.Public(tx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ? AND user_id != ?", levelID, userID).
+		Limit(1).
+		Find(&level)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrLevelNotFound
+	}
+
+	return nil
+}
+
+func findVote(tx *gorm.DB, userID uuid.UUID, levelID uuid.UUID) (*model.Vote, error) {
+	var vote model.Vote
+
+	result := tx.Where("user_id = ? AND level_id = ?", userID, levelID).Limit(1).Find(&vote)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &vote, nil
+}
+
+func addToCounter(tx *gorm.DB, levelID uuid.UUID, voteType model.VoteType, delta int) error {
+	column := counterColumn(voteType)
+
+	return tx.Model(&model.Level{}).Where("id = ?", levelID).Update(column, gorm.Expr(column+" + ?", delta)).Error
+}
+
+// Cast stores the vote of the user on the level and updates the counters of the level.
+// Voting on own levels isn't possible.
+func Cast(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID, voteType model.VoteType) (*model.Vote, error) {
+	if !IsValidVoteType(voteType) {
+		return nil, ErrInvalidVoteType
+	}
+
+	var vote *model.Vote
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockLevel(tx, userID, levelID); err != nil {
+			return err
+		}
+
+		var err error
+
+		vote, err = findVote(tx, userID, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if vote == nil {
+			vote = &model.Vote{
+				UserID:  userID,
+				LevelID: levelID,
+				Type:    voteType,
+			}
+
+			if err := tx.Create(vote).Error; err != nil {
+				return err
+			}
+
+			return addToCounter(tx, levelID, voteType, 1)
+		}
+
+		if vote.Type == voteType {
+			return nil
+		}
+
+		if err := addToCounter(tx, levelID, vote.Type, -1); err != nil {
+			return err
+		}
+
+		vote.Type = voteType
+
+		if err := tx.Model(vote).Update("type", voteType).Error; err != nil {
+			return err
+		}
+
+		return addToCounter(tx, levelID, voteType, 1)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vote, nil
+}
+
+// Retract removes the vote of the user on the level
+func Retract(db *gorm.DB, userID uuid.UUID, levelID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lockLevel(tx, userID, levelID); err != nil {
+			return err
+		}
+
+		vote, err := findVote(tx, userID, levelID)
+
+		if err != nil {
+			return err
+		}
+
+		if vote == nil {
+			return ErrNoVote
+		}
+
+		if err := tx.Delete(vote).Error; err != nil {
+			return err
+		}
+
+		return addToCounter(tx, levelID, vote.Type, -1)
+	})
+}
+
+// FillMyVotes sets MyVote on the levels to the votes of the user
+func FillMyVotes(db *gorm.DB, userID uuid.UUID, levels []model.Level) error {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	levelIDs := make([]uuid.UUID, len(levels))
+
+	for i, level := range levels {
+		levelIDs[i] = level.ID
+	}
+
+	votes := []model.Vote{}
+
+	if err := db.Where("user_id = ? AND level_id IN ?", userID, levelIDs).Find(&votes).Error; err != nil {
+		return err
+	}
+
+	voteTypes := map[uuid.UUID]model.VoteType{}
+
+	for _, vote := range votes {
+		voteTypes[vote.LevelID] = vote.Type
+	}
+
+	for i := range levels {
+		if voteType, ok := voteTypes[levels[i].ID]; ok {
+			levels[i].MyVote = &voteType
+		}
+	}
+
+	return nil
+}
+
+// ReconcileCounters recounts the votes of all levels whose counters drifted,
+// e.g. after votes were dropped by an account merge or deletion
+func ReconcileCounters(tx *gorm.DB) error {
+	return tx.Exec(`
+		UPDATE levels SET likes = c.likes, dislikes = c.dislikes
+		FROM (
+			SELECT l.id,
+				count(v.id) FILTER (WHERE v.type = ?) AS likes,
+				count(v.id) FILTER (WHERE v.type = ?) AS dislikes
+			FROM levels l LEFT JOIN votes v ON v.level_id = l.id
+			GROUP BY l.id
+		) c
+		WHERE c.id = levels.id AND (levels.likes != c.likes OR levels.dislikes != c.dislikes)`,
+		model.VoteLike, model.VoteDislike,
+	).Error
+}
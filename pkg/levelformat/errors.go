@@ -0,0 +1,68 @@
+package levelformat
+
+import (
+	"fmt"
+	"strings"
+)
+
+const CodeTooLarge = "too-large"
+const CodeSyntax = "syntax"
+const CodeUnsupportedVersion = "unsupported-version"
+const CodeInvalidSize = "invalid-size"
+const CodeMissingSpawn = "missing-spawn"
+const CodeMissingGoal = "missing-goal"
+const CodeOutOfBounds = "out-of-bounds"
+const CodeOverlap = "overlap"
+const CodeUnknownType = "unknown-type"
+const CodeTooMany = "too-many"
+
+// maxErrors caps the reported errors, a broken level easily has thousands of them
+const maxErrors = 50
+
+// Error is a single problem of level content. Path points to the offending field, e.g. objects[3].path[0].
+type Error struct {
+	Path    string `json:"path,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+
+	return e.Path + ": " + e.Message
+}
+
+// Errors are all problems found in level content
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(path string, code string, format string, args ...interface{}) {
+	if len(*e) >= maxErrors {
+		return
+	}
+
+	*e = append(*e, Error{
+		Path:    path,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
@@ -0,0 +1,242 @@
+// Package levelformat parses and validates the content of user made levels.
+//
+// A level is a JSON document:
+//
+//	{
+//	  "formatVersion": 1,
+//	  "width": 64,
+//	  "height": 32,
+//	  "spawn": {"x": 1, "y": 1},
+//	  "goal": {"x": 60, "y": 4},
+//	  "tiles": [{"x": 0, "y": 0, "type": "ground"}],
+//	  "objects": [{"type": "enemy", "x": 10, "y": 1, "path": [{"x": 14, "y": 1}]}]
+//	}
+//
+// Coordinates are grid cells with the origin in the bottom left corner.
+package levelformat
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const FormatVersion = 1
+
+type TileType = string
+
+const TileGround = TileType("ground")
+const TileWall = TileType("wall")
+const TilePlatform = TileType("platform")
+const TileSpikes = TileType("spikes")
+const TileWater = TileType("water")
+
+type ObjectType = string
+
+const ObjectEnemy = ObjectType("enemy")
+const ObjectMovingPlatform = ObjectType("moving-platform")
+const ObjectCheckpoint = ObjectType("checkpoint")
+const ObjectCollectible = ObjectType("collectible")
+const ObjectTrigger = ObjectType("trigger")
+const ObjectDoor = ObjectType("door")
+
+var tileTypes = map[TileType]bool{
+	TileGround:   true,
+	TileWall:     true,
+	TilePlatform: true,
+	TileSpikes:   true,
+	TileWater:    true,
+}
+
+// objectWeights is the share of an object in the complexity of a level, objects the game
+// has to simulate every frame weigh more than static ones
+var objectWeights = map[ObjectType]int{
+	ObjectEnemy:          25,
+	ObjectMovingPlatform: 15,
+	ObjectCheckpoint:     5,
+	ObjectCollectible:    2,
+	ObjectTrigger:        10,
+	ObjectDoor:           5,
+}
+
+// pathPointWeight is added to the complexity for every point of an object path
+const pathPointWeight = 3
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Tile struct {
+	X    int      `json:"x"`
+	Y    int      `json:"y"`
+	Type TileType `json:"type"`
+}
+
+type Object struct {
+	Type       ObjectType             `json:"type"`
+	X          int                    `json:"x"`
+	Y          int                    `json:"y"`
+	Path       []Point                `json:"path,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Level struct {
+	FormatVersion int      `json:"formatVersion"`
+	Width         int      `json:"width"`
+	Height        int      `json:"height"`
+	Spawn         *Point   `json:"spawn"`
+	Goal          *Point   `json:"goal"`
+	Tiles         []Tile   `json:"tiles"`
+	Objects       []Object `json:"objects"`
+}
+
+// Limits bounds the size of levels, 0 disables a limit
+type Limits struct {
+	MaxContentBytes int
+	MaxWidth        int
+	MaxHeight       int
+	MaxTiles        int
+	MaxObjects      int
+	MaxPathPoints   int
+}
+
+// Parse decodes and validates level content. Invalid content results in Errors.
+func Parse(content string, limits Limits) (*Level, error) {
+	if limits.MaxContentBytes > 0 && len(content) > limits.MaxContentBytes {
+		return nil, Errors{{
+			Code:    CodeTooLarge,
+			Message: fmt.Sprintf("content is %d bytes, at most %d are allowed", len(content), limits.MaxContentBytes),
+		}}
+	}
+
+	var level Level
+
+	// unlike a decoder, unmarshalling rejects data after the level
+	if err := json.Unmarshal([]byte(content), &level); err != nil {
+		return nil, Errors{{
+			Code:    CodeSyntax,
+			Message: err.Error(),
+		}}
+	}
+
+	if err := level.Validate(limits); err != nil {
+		return nil, err
+	}
+
+	return &level, nil
+}
+
+func (l *Level) inBounds(x int, y int) bool {
+	return x >= 0 && y >= 0 && x < l.Width && y < l.Height
+}
+
+// Validate checks the level against the format rules and the limits
+func (l *Level) Validate(limits Limits) error {
+	var errs Errors
+
+	if l.FormatVersion != FormatVersion {
+		errs.add("formatVersion", CodeUnsupportedVersion, "format version %d is not supported", l.FormatVersion)
+	}
+
+	if l.Width <= 0 || l.Height <= 0 {
+		errs.add("width", CodeInvalidSize, "level size %dx%d is invalid", l.Width, l.Height)
+
+		// without a size no position can be checked
+		return errs.err()
+	}
+
+	if limits.MaxWidth > 0 && l.Width > limits.MaxWidth {
+		errs.add("width", CodeInvalidSize, "width %d exceeds %d", l.Width, limits.MaxWidth)
+	}
+
+	if limits.MaxHeight > 0 && l.Height > limits.MaxHeight {
+		errs.add("height", CodeInvalidSize, "height %d exceeds %d", l.Height, limits.MaxHeight)
+	}
+
+	l.validatePoint(&errs, "spawn", l.Spawn, CodeMissingSpawn)
+	l.validatePoint(&errs, "goal", l.Goal, CodeMissingGoal)
+
+	if l.Spawn != nil && l.Goal != nil && *l.Spawn == *l.Goal {
+		errs.add("goal", CodeOverlap, "goal can't be at the spawn")
+	}
+
+	if limits.MaxTiles > 0 && len(l.Tiles) > limits.MaxTiles {
+		errs.add("tiles", CodeTooMany, "%d tiles exceed the limit of %d", len(l.Tiles), limits.MaxTiles)
+	}
+
+	occupied := make(map[Point]bool, len(l.Tiles))
+
+	for i, tile := range l.Tiles {
+		path := fmt.Sprintf("tiles[%d]", i)
+
+		if !tileTypes[tile.Type] {
+			errs.add(path+".type", CodeUnknownType, "unknown tile type %q", tile.Type)
+		}
+
+		if !l.inBounds(tile.X, tile.Y) {
+			errs.add(path, CodeOutOfBounds, "tile at %d,%d is outside the level", tile.X, tile.Y)
+		}
+
+		position := Point{X: tile.X, Y: tile.Y}
+
+		if occupied[position] {
+			errs.add(path, CodeOverlap, "another tile is already at %d,%d", tile.X, tile.Y)
+		}
+
+		occupied[position] = true
+	}
+
+	if l.Spawn != nil && occupied[*l.Spawn] {
+		errs.add("spawn", CodeOverlap, "spawn is inside a tile")
+	}
+
+	if limits.MaxObjects > 0 && len(l.Objects) > limits.MaxObjects {
+		errs.add("objects", CodeTooMany, "%d objects exceed the limit of %d", len(l.Objects), limits.MaxObjects)
+	}
+
+	for i, object := range l.Objects {
+		path := fmt.Sprintf("objects[%d]", i)
+
+		if _, ok := objectWeights[object.Type]; !ok {
+			errs.add(path+".type", CodeUnknownType, "unknown object type %q", object.Type)
+		}
+
+		if !l.inBounds(object.X, object.Y) {
+			errs.add(path, CodeOutOfBounds, "object at %d,%d is outside the level", object.X, object.Y)
+		}
+
+		if limits.MaxPathPoints > 0 && len(object.Path) > limits.MaxPathPoints {
+			errs.add(path+".path", CodeTooMany, "%d path points exceed the limit of %d", len(object.Path), limits.MaxPathPoints)
+		}
+
+		for j, point := range object.Path {
+			if !l.inBounds(point.X, point.Y) {
+				errs.add(fmt.Sprintf("%s.path[%d]", path, j), CodeOutOfBounds, "path point %d,%d is outside the level", point.X, point.Y)
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+func (l *Level) validatePoint(errs *Errors, path string, point *Point, missingCode string) {
+	if point == nil {
+		errs.add(path, missingCode, "%s is missing", path)
+		return
+	}
+
+	if !l.inBounds(point.X, point.Y) {
+		errs.add(path, CodeOutOfBounds, "%s at %d,%d is outside the level", path, point.X, point.Y)
+	}
+}
+
+// Complexity estimates how expensive the level is to load and simulate
+func (l *Level) Complexity() uint {
+	complexity := uint(len(l.Tiles))
+
+	for _, object := range l.Objects {
+		complexity += uint(objectWeights[object.Type] + len(object.Path)*pathPointWeight)
+	}
+
+	return complexity
+}
@@ -0,0 +1,141 @@
+package levelformat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const validLevel = `{
+	"formatVersion": 1,
+	"width": 16,
+	"height": 8,
+	"spawn": {"x": 1, "y": 1},
+	"goal": {"x": 14, "y": 1},
+	"tiles": [{"x": 0, "y": 0, "type": "ground"}, {"x": 1, "y": 0, "type": "ground"}],
+	"objects": [{"type": "enemy", "x": 8, "y": 1, "path": [{"x": 10, "y": 1}, {"x": 6, "y": 1}]}]
+}`
+
+// level builds content from the valid level with the fields replaced
+func level(t *testing.T, replacements ...string) string {
+	t.Helper()
+
+	content := validLevel
+
+	for i := 0; i < len(replacements); i += 2 {
+		if !strings.Contains(content, replacements[i]) {
+			t.Fatalf("%q isn't part of the level", replacements[i])
+		}
+
+		content = strings.Replace(content, replacements[i], replacements[i+1], 1)
+	}
+
+	return content
+}
+
+func TestParse(t *testing.T) {
+	parsed, err := Parse(validLevel, Limits{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Width != 16 || parsed.Height != 8 || len(parsed.Tiles) != 2 || len(parsed.Objects) != 1 {
+		t.Errorf("got %+v", parsed)
+	}
+
+	// two tiles, an enemy and two path points
+	if got, want := parsed.Complexity(), uint(2+25+2*3); got != want {
+		t.Errorf("got complexity %d, want %d", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limits  Limits
+		path    string
+		code    string
+	}{
+		{"syntax", `{"width": `, Limits{}, "", CodeSyntax},
+		{"trailing data", validLevel + ` {}`, Limits{}, "", CodeSyntax},
+		{"too large", validLevel, Limits{MaxContentBytes: 10}, "", CodeTooLarge},
+		{"version", level(t, `"formatVersion": 1`, `"formatVersion": 2`), Limits{}, "formatVersion", CodeUnsupportedVersion},
+		{"no size", level(t, `"width": 16`, `"width": 0`), Limits{}, "width", CodeInvalidSize},
+		{"too wide", validLevel, Limits{MaxWidth: 8}, "width", CodeInvalidSize},
+		{"too high", validLevel, Limits{MaxHeight: 4}, "height", CodeInvalidSize},
+		{"no spawn", level(t, `"spawn": {"x": 1, "y": 1},`, ``), Limits{}, "spawn", CodeMissingSpawn},
+		{"no goal", level(t, `"goal": {"x": 14, "y": 1},`, ``), Limits{}, "goal", CodeMissingGoal},
+		{"goal outside", level(t, `"goal": {"x": 14, "y": 1}`, `"goal": {"x": 16, "y": 1}`), Limits{}, "goal", CodeOutOfBounds},
+		{"goal at spawn", level(t, `"goal": {"x": 14, "y": 1}`, `"goal": {"x": 1, "y": 1}`), Limits{}, "goal", CodeOverlap},
+		{"spawn in tile", level(t, `"spawn": {"x": 1, "y": 1}`, `"spawn": {"x": 1, "y": 0}`), Limits{}, "spawn", CodeOverlap},
+		{"tile type", level(t, `"type": "ground"`, `"type": "lava"`), Limits{}, "tiles[0].type", CodeUnknownType},
+		{"tile outside", level(t, `{"x": 0, "y": 0, "type": "ground"}`, `{"x": -1, "y": 0, "type": "ground"}`), Limits{}, "tiles[0]", CodeOutOfBounds},
+		{"tile overlap", level(t, `{"x": 1, "y": 0, "type": "ground"}`, `{"x": 0, "y": 0, "type": "wall"}`), Limits{}, "tiles[1]", CodeOverlap},
+		{"too many tiles", validLevel, Limits{MaxTiles: 1}, "tiles", CodeTooMany},
+		{"object type", level(t, `"type": "enemy"`, `"type": "dragon"`), Limits{}, "objects[0].type", CodeUnknownType},
+		{"object outside", level(t, `"x": 8, "y": 1`, `"x": 8, "y": 8`), Limits{}, "objects[0]", CodeOutOfBounds},
+		{"too many objects", level(t, `"objects": [`, `"objects": [{"type": "door", "x": 3, "y": 1}, `), Limits{MaxObjects: 1}, "objects", CodeTooMany},
+		{"path outside", level(t, `{"x": 6, "y": 1}`, `{"x": 6, "y": -1}`), Limits{}, "objects[0].path[1]", CodeOutOfBounds},
+		{"too many path points", validLevel, Limits{MaxPathPoints: 1}, "objects[0].path", CodeTooMany},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.content, tt.limits)
+
+			var errs Errors
+
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want format errors", err)
+			}
+
+			for _, e := range errs {
+				if e.Path == tt.path && e.Code == tt.code {
+					return
+				}
+			}
+
+			t.Errorf("got %v, want %s at %q", errs, tt.code, tt.path)
+		})
+	}
+}
+
+func TestParseWithinLimits(t *testing.T) {
+	limits := Limits{
+		MaxContentBytes: len(validLevel),
+		MaxWidth:        16,
+		MaxHeight:       8,
+		MaxTiles:        2,
+		MaxObjects:      1,
+		MaxPathPoints:   2,
+	}
+
+	if _, err := Parse(validLevel, limits); err != nil {
+		t.Errorf("got %v for a level at the limits", err)
+	}
+}
+
+func TestParseErrorCap(t *testing.T) {
+	tiles := make([]string, maxErrors*2)
+
+	for i := range tiles {
+		tiles[i] = fmt.Sprintf(`{"x": %d, "y": 0, "type": "lava"}`, i%16)
+	}
+
+	content := level(t, `{"x": 0, "y": 0, "type": "ground"}, {"x": 1, "y": 0, "type": "ground"}`, strings.Join(tiles, ", "))
+
+	_, err := Parse(content, Limits{})
+
+	var errs Errors
+
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want format errors", err)
+	}
+
+	if len(errs) != maxErrors {
+		t.Errorf("got %d errors, want %d", len(errs), maxErrors)
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIScope = string
+
+const ScopeLevelsReadUnvalidated = APIScope("levels:read-unvalidated")
+const ScopeLevelsValidate = APIScope("levels:validate")
+
+// APIKey is a long-lived credential of a validation agent. Only the hash of the key is stored.
+type APIKey struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	KeyHash     string     `gorm:"not null;index:idx_api_key_hash,unique" json:"-"`
+	Scopes      []APIScope `gorm:"type:jsonb;serializer:json" json:"scopes"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null" json:"userId"`
+	User        *User      `json:"-"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid" json:"createdById"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+}
+
+func (k *APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (k *APIKey) HasScope(scope APIScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction = string
+
+const AuditRoleChanged = AuditAction("role-changed")
+const AuditAPIKeyCreated = AuditAction("api-key-created")
+const AuditAPIKeyRevoked = AuditAction("api-key-revoked")
+const AuditUserBanned = AuditAction("user-banned")
+const AuditBanLifted = AuditAction("ban-lifted")
+const AuditAccountMerged = AuditAction("account-merged")
+const AuditLevelHidden = AuditAction("level-hidden")
+const AuditLevelUnhidden = AuditAction("level-unhidden")
+
+type AuditSource = string
+
+const AuditSourceAPI = AuditSource("api")
+const AuditSourceCLI = AuditSource("cli")
+
+// AuditEntry records an administrative action. ActorID is empty for actions taken through the cli.
+type AuditEntry struct {
+	ID        uuid.UUID              `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	ActorID   *uuid.UUID             `gorm:"type:uuid" json:"actorId"`
+	Source    AuditSource            `gorm:"type:string" json:"source"`
+	Action    AuditAction            `gorm:"type:string" json:"action"`
+	TargetID  uuid.UUID              `gorm:"type:uuid;index" json:"targetId"`
+	Details   map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"details"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+func (a *AuditEntry) TableName() string {
+	return "audit_entries"
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BanScope = string
+
+const BanScopeFull = BanScope("full")
+const BanScopeUpload = BanScope("upload")
+const BanScopeVote = BanScope("vote")
+const BanScopeReport = BanScope("report")
+
+// Ban restricts a user either fully or for a single scope. Bans without expiry are permanent.
+type Ban struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	User       *User      `json:"-"`
+	IssuedByID uuid.UUID  `gorm:"type:uuid" json:"issuedById"`
+	Reason     string     `json:"reason"`
+	Scope      BanScope   `gorm:"type:string" json:"scope"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LiftedAt   *time.Time `json:"liftedAt"`
+	LiftedByID *uuid.UUID `gorm:"type:uuid" json:"liftedById"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (b *Ban) TableName() string {
+	return "bans"
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeletionPolicy = string
+
+// DeletionPolicyAnonymise keeps published levels and votes but strips every reference to the player
+const DeletionPolicyAnonymise = DeletionPolicy("anonymise")
+
+// DeletionPolicyDelete removes the account including all levels, votes and reports
+const DeletionPolicyDelete = DeletionPolicy("delete")
+
+// DeletionRequest is a pending account deletion, executed after the grace period unless cancelled
+type DeletionRequest struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"userId"`
+	Policy       DeletionPolicy `gorm:"type:string" json:"policy"`
+	RequestedAt  time.Time      `json:"requestedAt"`
+	ExecuteAfter time.Time      `gorm:"index" json:"executeAfter"`
+	CompletedAt  *time.Time     `json:"completedAt"`
+	CancelledAt  *time.Time     `json:"cancelledAt"`
+}
+
+func (d *DeletionRequest) TableName() string {
+	return "deletion_requests"
+}
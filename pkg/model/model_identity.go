@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity is a platform account a user can log in with. A user can own identities of several platforms.
+type UserIdentity struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID    `gorm:"type:uuid;not null;index" json:"-"`
+	User           *User        `json:"-"`
+	PlatformType   PlatformType `gorm:"type:string;not null;index:idx_identity_platform_unique,unique" json:"platformType"`
+	PlatformUserID string       `gorm:"not null;index:idx_identity_platform_unique,unique" json:"platformUserId"`
+	PlatformName   string       `json:"platformName"`
+	CreatedAt      time.Time    `json:"createdAt"`
+}
+
+func (i *UserIdentity) TableName() string {
+	return "user_identities"
+}
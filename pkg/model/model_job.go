@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// JobRun holds the outcome of the last run of a scheduled job, shared by all replicas
+type JobRun struct {
+	Name         string    `gorm:"primaryKey" json:"name"`
+	LastRunAt    time.Time `json:"lastRunAt"`
+	LastDuration int64     `json:"lastDurationMs"`
+	LastError    string    `json:"lastError"`
+	LastRunBy    string    `json:"lastRunBy"`
+	Runs         uint      `json:"runs"`
+}
+
+func (j *JobRun) TableName() string {
+	return "job_runs"
+}
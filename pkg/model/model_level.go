@@ -6,24 +6,102 @@ import (
 	"github.com/google/uuid"
 )
 
+type LevelState = string
+
+// A level is a draft until its creator submits it for review. Validation publishes or rejects the
+// submitted revision, a published revision stays live while newer revisions are drafted or reviewed.
+const LevelStateDraft = LevelState("draft")
+const LevelStatePending = LevelState("pending")
+const LevelStatePublished = LevelState("published")
+const LevelStateRejected = LevelState("rejected")
+const LevelStateHidden = LevelState("hidden")
+
 type Level struct {
-	ID                uuid.UUID   `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
-	UserID            uuid.UUID   `gorm:"type:uuid;not null" json:"-"`
-	User              *User       `json:"user"`
-	Name              string      `json:"name"`
-	Content           string      `json:"content"`
-	AuthorReplay      string      `json:"replay"`
-	Thumbnail         []uint8     `json:"image"`
-	ValidationId      *uuid.UUID  `gorm:"type:uuid;" json:"-"`
-	Validation        *Validation `json:"validation"`
-	Version           uint        `json:"version"`
-	Reports           uint        `json:"-"`
-	Published         time.Time   `json:"published"`
-	AuthorScore       int         `json:"score"`
-	ValidationLock    time.Time   `json:"-"`
-	ValidationAgentID *uuid.UUID  `json:"-"`
+	ID                uuid.UUID                `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	UserID            uuid.UUID                `gorm:"type:uuid;not null" json:"-"`
+	User              *User                    `json:"user"`
+	Name              string                   `json:"name"`
+	ShareCode         *string                  `gorm:"uniqueIndex" json:"shareCode"`
+	Content           string                   `json:"content,omitempty"`
+	AuthorReplay      string                   `json:"replay,omitempty"`
+	ContentKey        string                   `json:"-"`
+	AuthorReplayKey   string                   `json:"-"`
+	Thumbnail         []uint8                  `json:"-"`
+	Thumbnails        map[ThumbnailSize]string `gorm:"-" json:"thumbnails"`
+	ValidationId      *uuid.UUID               `gorm:"type:uuid;" json:"-"`
+	Validation        *Validation              `json:"validation"`
+	Version           uint                     `json:"version"`
+	State             LevelState               `gorm:"type:string;index" json:"state"`
+	HiddenFromState   LevelState               `gorm:"type:string" json:"-"`
+	PublishedVersion  uint                     `gorm:"not null;default:0" json:"publishedVersion"`
+	Reports           uint                     `json:"-"`
+	Published         time.Time                `json:"published"`
+	AuthorScore       int                      `json:"score"`
+	Difficulty        uint                     `json:"difficulty"`
+	Complexity        uint                     `gorm:"not null;default:0" json:"complexity"`
+	Likes             uint                     `gorm:"not null;default:0" json:"likes"`
+	Dislikes          uint                     `gorm:"not null;default:0" json:"dislikes"`
+	MyVote            *VoteType                `gorm:"-" json:"myVote"`
+	Plays             uint                     `gorm:"not null;default:0" json:"plays"`
+	UniquePlayers     uint                     `gorm:"not null;default:0" json:"uniquePlayers"`
+	Clears            uint                     `gorm:"not null;default:0" json:"clears"`
+	ClearRate         float64                  `gorm:"not null;default:0" json:"clearRate"`
+	HotScore          float64                  `gorm:"not null;default:0;index" json:"-"`
+	TrendingScore     float64                  `gorm:"not null;default:0;index" json:"-"`
+	ValidationLock    time.Time                `json:"-"`
+	ValidationAgentID *uuid.UUID               `json:"-"`
 }
 
 func (l *Level) TableName() string {
 	return "levels"
 }
+
+// LevelSummary is the part of a level shown in level lists, content and replay are fetched per level
+type LevelSummary struct {
+	ID               uuid.UUID  `json:"id"`
+	Name             string     `json:"name"`
+	ShareCode        *string    `json:"shareCode"`
+	AuthorID         uuid.UUID  `json:"authorId"`
+	AuthorName       string     `json:"authorName"`
+	Version          uint       `json:"version"`
+	State            LevelState `json:"state"`
+	PublishedVersion uint       `json:"publishedVersion"`
+	Difficulty       uint       `json:"difficulty"`
+	Likes            uint       `json:"likes"`
+	Dislikes         uint       `json:"dislikes"`
+	MyVote           *VoteType  `json:"myVote"`
+	Plays            uint       `json:"plays"`
+	UniquePlayers    uint       `json:"uniquePlayers"`
+	Clears           uint       `json:"clears"`
+	ClearRate        float64    `json:"clearRate"`
+	ThumbnailURL     string     `json:"thumbnailUrl"`
+	Published        time.Time  `json:"published"`
+}
+
+func (l *Level) Summary() LevelSummary {
+	summary := LevelSummary{
+		ID:               l.ID,
+		Name:             l.Name,
+		ShareCode:        l.ShareCode,
+		AuthorID:         l.UserID,
+		Version:          l.Version,
+		State:            l.State,
+		PublishedVersion: l.PublishedVersion,
+		Difficulty:       l.Difficulty,
+		Likes:            l.Likes,
+		Dislikes:         l.Dislikes,
+		MyVote:           l.MyVote,
+		Plays:            l.Plays,
+		UniquePlayers:    l.UniquePlayers,
+		Clears:           l.Clears,
+		ClearRate:        l.ClearRate,
+		ThumbnailURL:     l.Thumbnails[ThumbnailSmall],
+		Published:        l.Published,
+	}
+
+	if l.User != nil {
+		summary.AuthorName = l.User.PlatformName
+	}
+
+	return summary
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PlayOutcome = string
+
+const PlayOutcomeClear = PlayOutcome("clear")
+const PlayOutcomeFail = PlayOutcome("fail")
+const PlayOutcomeQuit = PlayOutcome("quit")
+
+// Play is a session of a user playing a level. It starts without outcome, which is set once
+// when the session is finished. TimeMs is the play time measured by the game.
+type Play struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	LevelID    uuid.UUID    `gorm:"type:uuid;not null;index:idx_plays_level_user" json:"levelId"`
+	Level      *Level       `json:"-"`
+	UserID     uuid.UUID    `gorm:"type:uuid;not null;index:idx_plays_level_user;index" json:"userId"`
+	User       *User        `json:"-"`
+	Version    uint         `json:"version"`
+	Outcome    *PlayOutcome `gorm:"type:string" json:"outcome"`
+	TimeMs     uint         `json:"timeMs"`
+	Deaths     uint         `json:"deaths"`
+	StartedAt  time.Time    `json:"startedAt"`
+	FinishedAt *time.Time   `json:"finishedAt"`
+}
+
+func (p *Play) TableName() string {
+	return "plays"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LevelRevision is a submitted state of a level. The level itself holds its published revision,
+// or its latest revision as long as none was published.
+type LevelRevision struct {
+	ID              uuid.UUID   `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	LevelID         uuid.UUID   `gorm:"type:uuid;not null;index:idx_level_revisions_version,unique" json:"levelId"`
+	Version         uint        `gorm:"not null;index:idx_level_revisions_version,unique" json:"version"`
+	Name            string      `json:"name"`
+	Content         string      `json:"content"`
+	AuthorReplay    string      `json:"replay"`
+	ContentKey      string      `json:"-"`
+	AuthorReplayKey string      `json:"-"`
+	Difficulty      uint        `json:"difficulty"`
+	Complexity      uint        `json:"complexity"`
+	ValidationID    *uuid.UUID  `gorm:"type:uuid" json:"-"`
+	Validation      *Validation `json:"validation"`
+	CreatedAt       time.Time   `json:"createdAt"`
+}
+
+func (r *LevelRevision) TableName() string {
+	return "level_revisions"
+}
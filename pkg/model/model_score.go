@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScoreStatus = string
+
+// Accepted scores are ranked, flagged scores looked implausible to the replay verifier
+// and are kept for review but left out of the leaderboards.
+const ScoreStatusAccepted = ScoreStatus("accepted")
+const ScoreStatusFlagged = ScoreStatus("flagged")
+
+// Score is the best score of a user on a level, higher is better. Scores belong to the level
+// version they were played on, publishing a new version starts a new leaderboard. Accepted and
+// flagged scores are kept apart, so a flagged score never replaces the accepted best.
+type Score struct {
+	ID         uuid.UUID   `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	LevelID    uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_scores_level_user_status" json:"levelId"`
+	Level      *Level      `json:"-"`
+	UserID     uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_scores_level_user_status;index" json:"userId"`
+	User       *User       `json:"-"`
+	Version    uint        `json:"version"`
+	Score      int         `gorm:"index" json:"score"`
+	TimeMs     uint        `json:"timeMs"`
+	ReplayKey  string      `json:"-"`
+	Status     ScoreStatus `gorm:"type:string;uniqueIndex:idx_scores_level_user_status" json:"status"`
+	FlagReason string      `json:"-"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
+
+func (s *Score) TableName() string {
+	return "scores"
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ThumbnailSize = string
+
+const ThumbnailSmall = ThumbnailSize("small")
+const ThumbnailLarge = ThumbnailSize("large")
+
+// Thumbnail is a rendition of the thumbnail of a level. Every upload gets new ids,
+// so a thumbnail never changes and can be cached forever. Pending thumbnails were uploaded by
+// the creator of a published level and replace the live ones once the next revision is validated.
+type Thumbnail struct {
+	ID          uuid.UUID     `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	LevelID     uuid.UUID     `gorm:"type:uuid;not null;index:idx_thumbnails_level_size_pending,unique" json:"levelId"`
+	Size        ThumbnailSize `gorm:"type:string;not null;index:idx_thumbnails_level_size_pending,unique" json:"size"`
+	Pending     bool          `gorm:"not null;default:false;index:idx_thumbnails_level_size_pending,unique" json:"pending"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	ContentType string        `json:"contentType"`
+	Data        []byte        `json:"-"`
+	BlobKey     string        `json:"-"`
+	Hash        string        `json:"-"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+func (t *Thumbnail) TableName() string {
+	return "thumbnails"
+}
@@ -6,14 +6,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserToken is a login session of a user. The access token and the refresh token are rotated in place,
+// so the row lives as long as the session does.
 type UserToken struct {
-	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"-"`
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	UserID     uuid.UUID `gorm:"not null;index:idx_user_token_unique,unique" json:"-"`
 	User       *User     `json:"-"`
 	Token      string    `gorm:"not null;index:idx_user_token_unique,unique" json:"-"`
 	ValidUntil time.Time `gorm:"not null" json:"-"`
+	// sha256 of the opaque refresh token, rotated on every refresh
+	RefreshTokenHash  string       `json:"-"`
+	RefreshValidUntil time.Time    `json:"expiresAt"`
+	DeviceLabel       string       `json:"deviceLabel"`
+	Platform          PlatformType `gorm:"type:string" json:"platform"`
+	CreatedAt         time.Time    `json:"createdAt"`
+	LastUsedAt        time.Time    `json:"lastUsedAt"`
+	Current           bool         `gorm:"-" json:"current"`
 }
 
 func (t *UserToken) TableName() string {
 	return "user_tokens"
-}
\ No newline at end of file
+}
@@ -17,11 +17,12 @@ type UserRole = string
 const UserRolePlayer = ResultType("player")
 const UserRoleMod = ResultType("mod")
 const UserRoleAgent = ResultType("agent")
+const UserRoleAdmin = UserRole("admin")
 
 type User struct {
 	ID             uuid.UUID    `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
-	PlatformType   PlatformType `gorm:"type:string" json:"platformType"`
-	PlatformUserID string       `gorm:"index:idx_platform_id_unique,unique" json:"platformUserId"`
+	PlatformType   PlatformType `gorm:"type:string;index:idx_users_platform,unique" json:"platformType"`
+	PlatformUserID string       `gorm:"index:idx_users_platform,unique" json:"platformUserId"`
 	PlatformName   string       `json:"platformName"`
 	Role           UserRole     `gorm:"default:player" json:"-"`
 	CreatedAt      time.Time    `json:"createdAt"`
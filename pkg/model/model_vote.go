@@ -1,6 +1,10 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type VoteType = string
 
@@ -8,12 +12,14 @@ const VoteLike = VoteType("like")
 const VoteDislike = VoteType("dislike")
 
 type Vote struct {
-	ID      uuid.UUID `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
-	UserID  uuid.UUID `gorm:"type:uuid;not null" json:"userId"`
-	User    *User     `json:"-"`
-	LevelID uuid.UUID `gorm:"type:uuid;not null" json:"levelId"`
-	Level   *Level    `json:"-"`
-	Type    VoteType  `gorm:"type:string" json:"type"`
+	ID        uuid.UUID `gorm:"type:uuid;primary;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_votes_user_level" json:"userId"`
+	User      *User     `json:"-"`
+	LevelID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_votes_user_level" json:"levelId"`
+	Level     *Level    `json:"-"`
+	Type      VoteType  `gorm:"type:string" json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 func (v *Vote) TableName() string {
@@ -0,0 +1,168 @@
+// Package sharecode generates and reads the short codes players use to share levels, e.g. XKT-4QP-9LM.
+//
+// Codes have eight random characters and a check character. The alphabet leaves out the letters
+// O, I, L, U and Z, which are read back as 0, 1, 1, V and 2. Its size of 31 is prime, so the weighted
+// check catches every single wrong character and every swap of two neighbouring characters.
+package sharecode
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXY"
+
+const dataLength = 8
+const length = dataLength + 1
+const groupLength = 3
+
+var ErrInvalid = errors.New("share code invalid")
+var ErrChecksum = errors.New("share code check character doesn't match")
+
+// confusables maps characters left out of the alphabet to the ones they are mistaken for
+var confusables = map[rune]rune{
+	'O': '0',
+	'I': '1',
+	'L': '1',
+	'U': 'V',
+	'Z': '2',
+}
+
+// checkInverse is the inverse of the weight of the check character modulo the alphabet size
+var checkInverse = inverse(length)
+
+func inverse(n int) int {
+	size := len(alphabet)
+
+	for i := 1; i < size; i++ {
+		if (n*i)%size == 1 {
+			return i
+		}
+	}
+
+	panic("sharecode: alphabet size must be prime")
+}
+
+// weightedSum weights every character with its position starting at 1
+func weightedSum(code string) int {
+	sum := 0
+
+	for i, c := range code {
+		sum += (i + 1) * strings.IndexRune(alphabet, c)
+	}
+
+	return sum % len(alphabet)
+}
+
+// checkCharacter completes the data characters so the weighted sum of the whole code is 0
+func checkCharacter(data string) byte {
+	size := len(alphabet)
+
+	return alphabet[((size-weightedSum(data))*checkInverse)%size]
+}
+
+func valid(code string) bool {
+	return weightedSum(code) == 0
+}
+
+func format(code string) string {
+	groups := make([]string, 0, length/groupLength)
+
+	for i := 0; i < length; i += groupLength {
+		groups = append(groups, code[i:i+groupLength])
+	}
+
+	return strings.Join(groups, "-")
+}
+
+// Generate returns a new random code
+func Generate() (string, error) {
+	data := make([]byte, dataLength)
+	size := big.NewInt(int64(len(alphabet)))
+
+	for i := range data {
+		n, err := rand.Int(rand.Reader, size)
+
+		if err != nil {
+			return "", err
+		}
+
+		data[i] = alphabet[n.Int64()]
+	}
+
+	return format(string(data) + string(checkCharacter(string(data)))), nil
+}
+
+// clean brings user input into the canonical form without separators, ignoring case,
+// whitespace and dashes and reading confusable characters as the ones they look like
+func clean(input string) (string, error) {
+	var b strings.Builder
+
+	for _, c := range strings.ToUpper(input) {
+		if c == '-' || c == ' ' || c == '\t' {
+			continue
+		}
+
+		if replacement, ok := confusables[c]; ok {
+			c = replacement
+		}
+
+		if !strings.ContainsRune(alphabet, c) {
+			return "", ErrInvalid
+		}
+
+		b.WriteRune(c)
+	}
+
+	if b.Len() != length {
+		return "", ErrInvalid
+	}
+
+	return b.String(), nil
+}
+
+// Normalize returns the code in the form it was generated in, or ErrInvalid if it can't be a code
+// and ErrChecksum if a character was mistyped
+func Normalize(input string) (string, error) {
+	code, err := clean(input)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !valid(code) {
+		return "", ErrChecksum
+	}
+
+	return format(code), nil
+}
+
+// Corrections returns all valid codes that differ from the input in a single character. A code with
+// a wrong check character has several of them, the one that exists is most likely the meant one.
+func Corrections(input string) []string {
+	code, err := clean(input)
+
+	if err != nil {
+		return nil
+	}
+
+	corrections := []string{}
+
+	for i := 0; i < length; i++ {
+		for _, c := range alphabet {
+			if rune(code[i]) == c {
+				continue
+			}
+
+			candidate := code[:i] + string(c) + code[i+1:]
+
+			if valid(candidate) {
+				corrections = append(corrections, format(candidate))
+			}
+		}
+	}
+
+	return corrections
+}
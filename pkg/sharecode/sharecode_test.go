@@ -0,0 +1,143 @@
+package sharecode
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fixed is a valid code built from known data
+var fixed = format("XKT4QP9M" + string(checkCharacter("XKT4QP9M")))
+
+func TestGenerate(t *testing.T) {
+	pattern := regexp.MustCompile(`^[` + alphabet + `]{3}-[` + alphabet + `]{3}-[` + alphabet + `]{3}$`)
+
+	for i := 0; i < 100; i++ {
+		code, err := Generate()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !pattern.MatchString(code) {
+			t.Fatalf("got malformed code %s", code)
+		}
+
+		if normalized, err := Normalize(code); err != nil || normalized != code {
+			t.Fatalf("generated code %s normalizes to %s, %v", code, normalized, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	compact := strings.ReplaceAll(fixed, "-", "")
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"formatted", fixed},
+		{"compact", compact},
+		{"lower case", strings.ToLower(fixed)},
+		{"whitespace", " " + compact[:3] + " " + compact[3:6] + "\t" + compact[6:] + " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Normalize(tt.input); err != nil || got != fixed {
+				t.Errorf("got %s, %v, want %s", got, err, fixed)
+			}
+		})
+	}
+}
+
+func TestNormalizeConfusables(t *testing.T) {
+	data := "0011V2AB"
+	code := format(data + string(checkCharacter(data)))
+
+	for _, input := range []string{"O0I-LUZ-AB", "o0i-luz-ab", "00I-1UZ-AB"} {
+		input = input + code[len(code)-1:]
+
+		if got, err := Normalize(input); err != nil || got != code {
+			t.Errorf("%s: got %s, %v, want %s", input, got, err, code)
+		}
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	for _, input := range []string{"", "ABC-DEF-GH", "ABC-DEF-GHJK", "ABC-DEF-GH!", "ABC-DEF-G_H"} {
+		if _, err := Normalize(input); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: got %v, want %v", input, err, ErrInvalid)
+		}
+	}
+}
+
+func TestNormalizeDetectsSubstitutions(t *testing.T) {
+	code := strings.ReplaceAll(fixed, "-", "")
+
+	for i := 0; i < length; i++ {
+		for _, c := range alphabet {
+			if rune(code[i]) == c {
+				continue
+			}
+
+			mistyped := code[:i] + string(c) + code[i+1:]
+
+			if _, err := Normalize(mistyped); !errors.Is(err, ErrChecksum) {
+				t.Errorf("%s: got %v, want %v", mistyped, err, ErrChecksum)
+			}
+		}
+	}
+}
+
+func TestNormalizeDetectsAdjacentSwaps(t *testing.T) {
+	for n := 0; n < 50; n++ {
+		generated, err := Generate()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code := strings.ReplaceAll(generated, "-", "")
+
+		for i := 0; i < length-1; i++ {
+			if code[i] == code[i+1] {
+				continue
+			}
+
+			swapped := code[:i] + string(code[i+1]) + string(code[i]) + code[i+2:]
+
+			if _, err := Normalize(swapped); !errors.Is(err, ErrChecksum) {
+				t.Errorf("%s: got %v, want %v", swapped, err, ErrChecksum)
+			}
+		}
+	}
+}
+
+func TestCorrections(t *testing.T) {
+	code := strings.ReplaceAll(fixed, "-", "")
+
+	// a wrong check character, the meant code is among the corrections
+	wrong := alphabet[(strings.IndexByte(alphabet, code[length-1])+1)%len(alphabet)]
+	mistyped := code[:length-1] + string(wrong)
+
+	corrections := Corrections(mistyped)
+	found := false
+
+	for _, correction := range corrections {
+		if _, err := Normalize(correction); err != nil {
+			t.Errorf("correction %s is invalid: %v", correction, err)
+		}
+
+		found = found || correction == fixed
+	}
+
+	if !found {
+		t.Errorf("%s not among the corrections %v", fixed, corrections)
+	}
+
+	if got := Corrections("too short"); got != nil {
+		t.Errorf("got %v for invalid input", got)
+	}
+}